@@ -0,0 +1,178 @@
+// Package cache stores the collected files (collect.File) on disk, keyed by the content hash of each file.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// Version is a part of the cache key, and the name of the subdirectory of the cached files.
+// It is changed when the format of collect.File is changed.
+const Version = versionPrefix + "v3"
+
+const versionPrefix = "commentof-cache-"
+
+type Cache struct {
+	Dir string // if empty, the files are cached in memory (see NewMemory)
+
+	mu     sync.Mutex
+	mem    map[string][]byte // key -> encoded file
+	latest map[string]string // filename -> key (only the latest version is kept in memory)
+}
+
+func New(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// NewMemory returns the cache storing the files in memory (e.g. for watch mode).
+func NewMemory() *Cache {
+	return &Cache{}
+}
+
+// DefaultDir returns the default cache directory (e.g. ~/.cache/commentof).
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "commentof"), nil
+}
+
+// Options are the options changing the collected files (e.g. constants=true). They are a part of the cache key.
+type Options map[string]string
+
+// encode returns the canonical encoding of the options (sorted by the name).
+func (o Options) encode() string {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%q=%q;", name, o[name])
+	}
+	return b.String()
+}
+
+// Key returns the cache key of the file. The filename is included, because the positions and the platform depend on it.
+func (c *Cache) Key(filename string, src []byte, options Options) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", Version, filename, options.encode())
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, Version, key[:2], key)
+}
+
+// Get returns the cached file. If it is found, the file is added to fset (the positions are relocated).
+func (c *Cache) Get(fset *token.FileSet, filename string, src []byte, options Options) (*collect.File, bool) {
+	b, ok := c.read(c.Key(filename, src, options))
+	if !ok {
+		return nil, false
+	}
+	tf := fset.AddFile(filename, -1, len(src))
+	tf.SetLinesForContent(src)
+	f, err := collect.DecodeFile(bytes.NewReader(b), tf)
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// Put stores the file collected from src with the options. tf is the token.File of the parsed file.
+func (c *Cache) Put(tf *token.File, src []byte, options Options, f *collect.File) error {
+	var buf bytes.Buffer
+	if err := collect.EncodeFile(&buf, f, tf); err != nil {
+		return fmt.Errorf("encode cache: %w", err)
+	}
+	return c.write(tf.Name(), c.Key(tf.Name(), src, options), buf.Bytes())
+}
+
+func (c *Cache) read(key string) ([]byte, bool) {
+	if c.Dir == "" {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		b, ok := c.mem[key]
+		return b, ok
+	}
+	b, err := os.ReadFile(c.path(key))
+	return b, err == nil
+}
+
+func (c *Cache) write(filename string, key string, b []byte) error {
+	if c.Dir == "" {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.mem == nil {
+			c.mem, c.latest = map[string][]byte{}, map[string]string{}
+		}
+		if prev, ok := c.latest[filename]; ok {
+			delete(c.mem, prev)
+		}
+		c.mem[key] = b
+		c.latest[filename] = key
+		return nil
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path) // atomic, for concurrent writers
+}
+
+// Clean removes the cached files of all versions (the versioned subdirectories of Dir). Dir itself is kept.
+// If Dir has the other files, it is not a cache directory (e.g. a mistyped -cache-dir), and nothing is removed.
+func (c *Cache) Clean() error {
+	if c.Dir == "" {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.mem, c.latest = nil, nil
+		return nil
+	}
+
+	entries, err := os.ReadDir(c.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("clean cache: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), versionPrefix) {
+			return fmt.Errorf("clean cache: %s is not a cache directory (%s is found)", c.Dir, e.Name())
+		}
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(c.Dir, e.Name())); err != nil {
+			return fmt.Errorf("clean cache: %w", err)
+		}
+	}
+	return nil
+}
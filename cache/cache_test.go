@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+const src = `package p
+
+// Person is the person.
+type Person struct {
+	Name string // name of the person
+}
+
+// Hello says hello.
+func Hello(p Person) string { return "hello " + p.Name }
+`
+
+// collectFile parses src and stores the collected file in the cache.
+func collectFile(t *testing.T, c *Cache, filename string, src []byte) *collect.File {
+	t.Helper()
+	fset := token.NewFileSet()
+	tree, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse: %+v", err)
+	}
+	f := collect.NewFile()
+	cc := &collect.Collector{Fset: fset, Dot: ".", Sharp: "#"}
+	if err := cc.CollectFromFile(f, tree); err != nil {
+		t.Fatalf("collect: %+v", err)
+	}
+	if err := c.Put(fset.File(tree.Pos()), src, nil, f); err != nil {
+		t.Fatalf("put: %+v", err)
+	}
+	return f
+}
+
+func TestCache(t *testing.T) {
+	cases := []struct {
+		name string
+		new  func(t *testing.T) *Cache
+	}{
+		{name: "memory", new: func(t *testing.T) *Cache { return NewMemory() }},
+		{name: "disk", new: func(t *testing.T) *Cache { return New(t.TempDir()) }},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Run("round trip", func(t *testing.T) {
+				cache := c.new(t)
+				want := collectFile(t, cache, "p.go", []byte(src))
+
+				fset := token.NewFileSet()
+				fset.AddFile("other.go", -1, 100) // the positions are relocated
+				got, ok := cache.Get(fset, "p.go", []byte(src), nil)
+				if !ok {
+					t.Fatalf("cache miss")
+				}
+				if !reflect.DeepEqual(got.Names, want.Names) {
+					t.Errorf("names: want %v, but got %v", want.Names, got.Names)
+				}
+				if got, want := got.Types["Person"].Doc, want.Types["Person"].Doc; got != want {
+					t.Errorf("doc: want %q, but got %q", want, got)
+				}
+				if got := fset.Position(got.Functions["Hello"].Pos).String(); got != "p.go:9:1" {
+					t.Errorf("position: want p.go:9:1, but got %s", got)
+				}
+			})
+
+			t.Run("invalidation", func(t *testing.T) {
+				cache := c.new(t)
+				collectFile(t, cache, "p.go", []byte(src))
+
+				misses := []struct {
+					name     string
+					filename string
+					src      string
+				}{
+					{name: "changed src", filename: "p.go", src: src + "\n// Bye says bye.\nfunc Bye() {}\n"},
+					{name: "changed filename", filename: "p_linux.go", src: src},
+				}
+				for _, m := range misses {
+					if _, ok := cache.Get(token.NewFileSet(), m.filename, []byte(m.src), nil); ok {
+						t.Errorf("%s: want the cache miss, but hit", m.name)
+					}
+				}
+				if _, ok := cache.Get(token.NewFileSet(), "p.go", []byte(src), nil); !ok {
+					t.Errorf("unchanged: want the cache hit, but miss")
+				}
+			})
+
+			t.Run("clean", func(t *testing.T) {
+				cache := c.new(t)
+				collectFile(t, cache, "p.go", []byte(src))
+				if err := cache.Clean(); err != nil {
+					t.Fatalf("clean: %+v", err)
+				}
+				if _, ok := cache.Get(token.NewFileSet(), "p.go", []byte(src), nil); ok {
+					t.Errorf("want the cache miss after clean, but hit")
+				}
+			})
+		})
+	}
+}
+
+func TestMemoryKeepsLatest(t *testing.T) {
+	cache := NewMemory()
+	v1 := []byte(src)
+	v2 := []byte(src + "\n// Bye says bye.\nfunc Bye() {}\n")
+
+	collectFile(t, cache, "p.go", v1)
+	collectFile(t, cache, "p.go", v2)
+	collectFile(t, cache, "q.go", v1)
+
+	if _, ok := cache.Get(token.NewFileSet(), "p.go", v1, nil); ok {
+		t.Errorf("the old version of p.go is kept")
+	}
+	if _, ok := cache.Get(token.NewFileSet(), "p.go", v2, nil); !ok {
+		t.Errorf("the latest version of p.go is not found")
+	}
+	if _, ok := cache.Get(token.NewFileSet(), "q.go", v1, nil); !ok {
+		t.Errorf("q.go is not found")
+	}
+	if got := len(cache.mem); got != 2 {
+		t.Errorf("entries: want 2, but got %d", got)
+	}
+}
+
+func TestDiskLayout(t *testing.T) {
+	dir := t.TempDir()
+	cache := New(dir)
+	collectFile(t, cache, "p.go", []byte(src))
+
+	key := cache.Key("p.go", []byte(src), nil)
+	if _, err := os.Stat(filepath.Join(dir, Version, key[:2], key)); err != nil {
+		t.Errorf("the cache file is not found: %+v", err)
+	}
+	tmps, _ := filepath.Glob(filepath.Join(dir, Version, key[:2], "tmp-*"))
+	if len(tmps) != 0 {
+		t.Errorf("the temporary files are left: %v", tmps)
+	}
+}
+
+func TestKey(t *testing.T) {
+	cache := NewMemory()
+	base := cache.Key("p.go", []byte(src), Options{"constants": "false", "conflict": "0"})
+
+	cases := []struct {
+		name     string
+		filename string
+		src      string
+		options  Options
+		wantSame bool
+	}{
+		{name: "same", filename: "p.go", src: src, options: Options{"constants": "false", "conflict": "0"}, wantSame: true},
+		{name: "changed option", filename: "p.go", src: src, options: Options{"constants": "true", "conflict": "0"}},
+		{name: "added option", filename: "p.go", src: src, options: Options{"constants": "false", "conflict": "0", "platform": "linux/amd64/"}},
+		{name: "no options", filename: "p.go", src: src},
+		{name: "changed src", filename: "p.go", src: src + "\n", options: Options{"constants": "false", "conflict": "0"}},
+		{name: "changed filename", filename: "q.go", src: src, options: Options{"constants": "false", "conflict": "0"}},
+		{name: "ambiguous encoding", filename: "p.go", src: src, options: Options{"constants": "false\";\"conflict\"=\"0"}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got := cache.Key(c.filename, []byte(c.src), c.options)
+			if same := got == base; same != c.wantSame {
+				t.Errorf("same key: want %v, but got %v", c.wantSame, same)
+			}
+		})
+	}
+}
+
+func TestGetWithOtherOptions(t *testing.T) {
+	cache := New(t.TempDir())
+	fset := token.NewFileSet()
+	tree, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse: %+v", err)
+	}
+	f := collect.NewFile()
+	if err := (&collect.Collector{Fset: fset, Dot: ".", Sharp: "#"}).CollectFromFile(f, tree); err != nil {
+		t.Fatalf("collect: %+v", err)
+	}
+	if err := cache.Put(fset.File(tree.Pos()), []byte(src), Options{"constants": "false"}, f); err != nil {
+		t.Fatalf("put: %+v", err)
+	}
+
+	if _, ok := cache.Get(token.NewFileSet(), "p.go", []byte(src), Options{"constants": "false"}); !ok {
+		t.Errorf("want the cache hit with the same options, but miss")
+	}
+	if _, ok := cache.Get(token.NewFileSet(), "p.go", []byte(src), Options{"constants": "true"}); ok {
+		t.Errorf("want the cache miss with the other options, but hit")
+	}
+}
+
+func TestClean(t *testing.T) {
+	cases := []struct {
+		name    string
+		files   []string // files other than the cache in the directory
+		wantErr bool
+	}{
+		{name: "cache only"},
+		{name: "old version", files: []string{versionPrefix + "v1/ab/abcd"}},
+		{name: "not a cache (file)", files: []string{".bashrc"}, wantErr: true},
+		{name: "not a cache (directory)", files: []string{"src/main.go"}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cache := New(dir)
+			collectFile(t, cache, "p.go", []byte(src))
+			for _, name := range c.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := cache.Clean()
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("want the error, but nil")
+				}
+				// nothing is removed
+				if _, ok := cache.Get(token.NewFileSet(), "p.go", []byte(src), nil); !ok {
+					t.Errorf("the cached file is removed")
+				}
+				for _, name := range c.files {
+					if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+						t.Errorf("%s is removed", name)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("the cache directory is removed: %+v", err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("entries: want none, but got %d", len(entries))
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		if err := New(filepath.Join(t.TempDir(), "missing")).Clean(); err != nil {
+			t.Errorf("unexpected error: %+v", err)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package commentof
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/podhmo/commentof/cache"
+	"github.com/podhmo/commentof/collect"
+)
+
+// WithCache enables the on-disk cache of the collected files (used by Dir and Load).
+// The cache is not used in the type-checked mode, because the syntax trees are needed.
+func WithCache(c *cache.Cache) Option {
+	return func(cfg *config) {
+		cfg.Cache = c
+	}
+}
+
+func useCache(cfg *config) bool {
+	return cfg.Cache != nil && cfg.TypeCheck == NoTypeCheck
+}
+
+// cacheOptions returns the options changing the collected files (or the files selected by them), for the cache key.
+func cacheOptions(cfg *config) cache.Options {
+	o := cache.Options{
+		"constants":          strconv.FormatBool(collectConstants(cfg)),
+		"include-test":       strconv.FormatBool(cfg.IncludeTestFile),
+		"include-unexported": strconv.FormatBool(!cfg.IgnoreExported),
+		"conflict":           strconv.Itoa(int(cfg.ConflictPolicy)),
+	}
+	if p := cfg.Platform; p != nil {
+		o["platform"] = p.GOOS + "/" + p.GOARCH + "/" + strings.Join(p.Tags, ",")
+	}
+	return o
+}
+
+// collectFile returns the collected file from the cache. If it is not cached, the file is parsed and collected, and stored in the cache.
+// If the file cannot be parsed at all, the syntax errors are returned (scanner.ErrorList).
+func collectFile(fset *token.FileSet, cfg *config, filename string) (*collect.File, error) {
+	src, ok := cfg.Overlay[absPath(filename)]
+	if ok && src == nil {
+		return nil, fmt.Errorf("%s is deleted by the overlay", filename)
+	}
+	if !ok {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		src = b
+	}
+
+	options := cacheOptions(cfg)
+	if f, ok := cfg.Cache.Get(fset, filename, src, options); ok {
+		for _, d := range f.Diagnostics { // replay
+			if cfg.Report != nil {
+				cfg.Report(d)
+			}
+			if cfg.Strict && d.Severity != collect.SeverityInfo {
+				return nil, d
+			}
+		}
+		return f, nil
+	}
+
+	t, parseErr := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if !hasPackageClause(t) {
+		return nil, parseErr
+	}
+	c := newCollector(fset, cfg)
+	c.ParseErrors = nil
+	if list := (scanner.ErrorList)(nil); errors.As(parseErr, &list) {
+		c.ParseErrors = list
+	}
+	f := collect.NewFile()
+	if err := c.CollectFromFile(f, t); err != nil {
+		return nil, fmt.Errorf("collect file: %s: %w", filename, err)
+	}
+	if err := cfg.Cache.Put(fset.File(t.Pos()), src, options, f); err != nil {
+		return nil, fmt.Errorf("cache: %w", err)
+	}
+	return f, nil
+}
+
+func loadCachedDir(fset *token.FileSet, cfg *config, dirname string, filter func(string) bool, options []Option) ([]*collect.Package, error) {
+	filenames, err := listDir(cfg.Overlay, dirname, filter)
+	if err != nil {
+		return nil, fmt.Errorf("parse dir: %w", err)
+	}
+
+	byName := map[string][]string{}
+	files := map[string]*collect.File{}
+	for _, filename := range filenames {
+		f, err := collectFile(fset, cfg, filename)
+		if err != nil {
+			if list := (scanner.ErrorList)(nil); errors.As(err, &list) {
+				continue // the same as parseDir
+			}
+			return nil, fmt.Errorf("collect: dir=%s, %w", dirname, err)
+		}
+		byName[f.Package] = append(byName[f.Package], filename)
+		files[filename] = f
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pkgs := make([]*collect.Package, 0, len(names))
+	for _, name := range names {
+		collected := make([]*collect.File, len(byName[name]))
+		for i, filename := range byName[name] {
+			collected[i] = files[filename]
+		}
+		p, err := assemble(fset, byName[name], collected, options...)
+		if err != nil {
+			return pkgs, fmt.Errorf("collect: dir=%s, name=%s, %w", dirname, name, err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+func loadCachedFiles(fset *token.FileSet, cfg *config, filenames []string, options []Option) (*collect.Package, error) {
+	files := make([]*collect.File, len(filenames))
+	for i, filename := range filenames {
+		f, err := collectFile(fset, cfg, filename)
+		if err != nil {
+			return nil, fmt.Errorf("parse file: %w", err)
+		}
+		files[i] = f
+	}
+	return assemble(fset, filenames, files, options...)
+}
+
+// assemble builds the package from the collected files.
+func assemble(fset *token.FileSet, filenames []string, files []*collect.File, options ...Option) (*collect.Package, error) {
+	cfg := defaultConfig()
+	for _, opt := range options {
+		opt(cfg)
+	}
+	b := cfg.PackageBuilder
+	b.Fset = fset
+
+	p := b.Package
+	p.Name, p.ImportPath, p.Fset, p.Dir = files[0].Package, cfg.ImportPath, fset, filepath.Dir(filenames[0])
+	for i, f := range files {
+		if err := b.AddFile(f, filenames[i]); err != nil {
+			return p, fmt.Errorf("add file: %s: %w", filenames[i], err)
+		}
+	}
+	return b.Build(), nil
+}
@@ -0,0 +1,37 @@
+package commentof
+
+import (
+	"testing"
+
+	"github.com/podhmo/commentof/cache"
+)
+
+func TestLoadCachedWithOptions(t *testing.T) {
+	c := cache.New(t.TempDir())
+
+	// the same cache is used with the different options, in order
+	cases := []struct {
+		name          string
+		options       []Option
+		wantConstants int
+	}{
+		{name: "default"},
+		{name: "include-constants", options: []Option{WithIncludeConstants(true)}, wantConstants: 5},
+		{name: "default again"},
+		{name: "include-constants again", options: []Option{WithIncludeConstants(true)}, wantConstants: 5},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			pkgs, err := Load([]string{"./testdata/fixture"}, append([]Option{WithCache(c)}, tc.options...)...)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			p := pkgs[0]
+			if got := len(p.Constants); got != tc.wantConstants {
+				t.Errorf("constants: want %d, but got %d (stale cache?)", tc.wantConstants, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/podhmo/commentof/cache"
+)
+
+// runCache runs the cache subcommand (go-commentof cache clean).
+func runCache(args []string) error {
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	dir := fs.String("cache-dir", "", "cache directory (default: $XDG_CACHE_HOME/commentof)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-commentof cache [-cache-dir <dir>] clean")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || fs.Arg(0) != "clean" {
+		fs.Usage()
+		return fmt.Errorf("unexpected cache command: %q", fs.Args())
+	}
+	if *dir == "" {
+		d, err := cache.DefaultDir()
+		if err != nil {
+			return err
+		}
+		*dir = d
+	}
+	return cache.New(*dir).Clean()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/podhmo/commentof"
+	"github.com/podhmo/commentof/collect"
+	"github.com/podhmo/commentof/emit"
+)
+
+// runHTML runs the html subcommand, generating the static site into the -o directory.
+func runHTML(ctx context.Context, args []string) error {
+	if options.Output == "" {
+		return fmt.Errorf("html: -o <dir> is required")
+	}
+
+	var pkgs []*collect.Package
+	for _, filename := range args {
+		loaded, err := commentof.LoadContext(ctx, []string{filename}, commentofOptions()...)
+		for _, p := range loaded {
+			printDiagnostics(p.Diagnostics)
+		}
+		pkgs = append(pkgs, loaded...)
+		if err != nil {
+			log.Printf("!! %+v", err)
+		}
+	}
+
+	site := &emit.Site{Dir: options.Output, SourceURL: options.SourceURL}
+	return site.Write(pkgs)
+}
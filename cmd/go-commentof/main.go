@@ -2,257 +2,332 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
-	"go/parser"
-	"go/token"
 	"io"
-	"io/fs"
 	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"os/signal"
 	"runtime"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/podhmo/commentof"
+	"github.com/podhmo/commentof/cache"
+	"github.com/podhmo/commentof/collect"
+	"github.com/podhmo/commentof/emit"
 )
 
 var options struct {
 	IncludeTestFile   bool
 	IncludeUnexported bool
+	IncludeConstants  bool
+	ResolveTypeDefs   bool
+	Implements        bool
+	TypeCheck         string
+	Conflict          string
+	Strict            bool
+	DiagnosticsJSON   bool
+	Overlay           string
+	StdinFilename     string
+	Workers           int
+	Cache             bool
+	CacheDir          string
+	Output            string
+	Format            string
+	Template          string
+	Symbols           string
+	TypeMap           string
+	GoCodePackage     string
+	GoCodeVar         string
+	Watch             bool
+	SourceURL         string
+	Addr              string
+	WatchInterval     time.Duration
+
+	Tags   string
+	GOOS   string
+	GOARCH string
 
 	All bool
 }
 
+var (
+	typeCheckMode  commentof.TypeCheckMode
+	conflictPolicy collect.ConflictPolicy
+	subcommand     string // html (go-commentof html -o <dir> <pattern>...), or serve (go-commentof serve -addr <addr> <pattern>...)
+)
+
+// subcommands are the names of the subcommands, the first argument is a subcommand unless it is an existing directory (e.g. ./cache).
+var subcommands = []string{"cache", "html", "serve"}
+
 func main() {
+	name, args := splitSubcommand(os.Args[1:])
+	if name == "cache" {
+		if err := runCache(args); err != nil {
+			log.Fatalf("!! %+v", err)
+		}
+		return
+	}
+	subcommand = name
+
 	flag.BoolVar(&options.IncludeTestFile, "include-test-file", false, "include *_test.go")
 	flag.BoolVar(&options.IncludeUnexported, "include-unexported", false, "include unexported symbols")
+	flag.BoolVar(&options.IncludeConstants, "include-constants", false, "include constants in the json formats (always included with -typecheck, and in the other formats)")
+	flag.BoolVar(&options.ResolveTypeDefs, "resolve-typedefs", false, "inherit the fields of in-package types from aliases and defined types")
+	flag.BoolVar(&options.Implements, "implements", false, "detect which types implement which interfaces")
+	flag.StringVar(&options.TypeCheck, "typecheck", "", "type-checked mode (none, source, export)")
+	flag.StringVar(&options.Conflict, "conflict", "variants", "what happens when the same symbol is defined twice (variants, first, error)")
+	flag.BoolVar(&options.Strict, "strict", false, "treat diagnostics (warning or error) as errors")
+	flag.BoolVar(&options.DiagnosticsJSON, "diagnostics-json", false, "print diagnostics to stderr as JSON (one per line)")
+	flag.StringVar(&options.Overlay, "overlay", "", "JSON file replacing the contents of files (the same format as go build -overlay)")
+	flag.StringVar(&options.StdinFilename, "stdin-filename", "<standard input>", "filename used for the source read from stdin (-)")
+	flag.IntVar(&options.Workers, "workers", 0, "number of packages collected concurrently (default: GOMAXPROCS)")
+	flag.BoolVar(&options.Cache, "cache", false, "cache the collected files, keyed by the content hash (not used with -typecheck)")
+	flag.StringVar(&options.CacheDir, "cache-dir", "", "cache directory (default: $XDG_CACHE_HOME/commentof)")
+	flag.StringVar(&options.Output, "o", "", "output file (default: stdout), or the output directory of the html subcommand")
+	flag.StringVar(&options.Format, "format", "json", "output format ("+strings.Join(emit.Formats, ", ")+")")
+	flag.StringVar(&options.Template, "template", "", "text/template file rendering each package (overrides -format)")
+	flag.StringVar(&options.Symbols, "symbols", "", "comma-separated list of the handlers of -format openapi, functions or methods (e.g. DeletePet,*Server#AddPet) (default: the ones having the route line in the doc)")
+	flag.StringVar(&options.TypeMap, "typemap", "", "JSON file mapping the Go types to the types of -format proto and graphql (see emit.TypeMap)")
+	flag.StringVar(&options.GoCodePackage, "gocode-package", "", "package name of -format gocode (default: the package itself)")
+	flag.StringVar(&options.GoCodeVar, "gocode-var", "Docs", "variable name of -format gocode")
+	flag.BoolVar(&options.Watch, "watch", false, "watch the source files, and re-emit the changed packages (rewrite the -o file, or stream NDJSON to stdout)")
+	flag.DurationVar(&options.WatchInterval, "watch-interval", 500*time.Millisecond, "polling interval of -watch")
+	flag.StringVar(&options.Tags, "tags", "", "comma-separated list of build tags")
+	flag.StringVar(&options.GOOS, "goos", "", "target GOOS (if not set, the definitions for each platform are kept as variants)")
+	flag.StringVar(&options.GOARCH, "goarch", "", "target GOARCH (if not set, the definitions for each platform are kept as variants)")
+	flag.StringVar(&options.SourceURL, "source-url", "", "link of the source positions in the html subcommand, {file} and {line} are replaced (default: relative paths to the local files)")
+	flag.StringVar(&options.Addr, "addr", ":8080", "address of the serve subcommand")
 	flag.BoolVar(&options.All, "all", false, "enable all options")
-	flag.Parse()
+	flag.CommandLine.Parse(args)
+
+	mode, err := commentof.ParseTypeCheckMode(options.TypeCheck)
+	if err != nil {
+		log.Fatalf("!! %+v", err)
+	}
+	typeCheckMode = mode
+	policy, err := commentof.ParseConflictPolicy(options.Conflict)
+	if err != nil {
+		log.Fatalf("!! %+v", err)
+	}
+	conflictPolicy = policy
+
+	if options.Overlay != "" {
+		m, err := loadOverlay(options.Overlay)
+		if err != nil {
+			log.Fatalf("!! %+v", err)
+		}
+		overlay = m
+	}
 
 	if options.All {
 		options.IncludeTestFile = true
 		options.IncludeUnexported = true
+		options.IncludeConstants = true
+		options.ResolveTypeDefs = true
+		options.Implements = true
 	}
 
-	fset := token.NewFileSet()
-	for _, filename := range flag.Args() {
-		if filename == "-" {
-			continue
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-		stat, err := os.Stat(filename)
-		if err != nil {
-			stdSrcFilename := filepath.Join(runtime.GOROOT(), "src", filename)
-			stat, err = os.Stat(stdSrcFilename)
-			if err != nil {
-				if err := runModulePackage(fset, filename); err != nil {
-					log.Printf("!! %+v", err)
-				}
-				continue
-			}
-			filename = stdSrcFilename
+	switch subcommand {
+	case "html":
+		if err := runHTML(ctx, flag.Args()); err != nil {
+			log.Fatalf("!! %+v", err)
+		}
+		return
+	case "serve":
+		if err := runServe(ctx, flag.Args()); err != nil {
+			log.Fatalf("!! %+v", err)
+		}
+		return
+	}
+	if options.Watch {
+		if err := runWatch(ctx, flag.Args()); err != nil {
+			log.Fatalf("!! %+v", err)
 		}
+		return
+	}
+
+	var w io.Writer = os.Stdout
+	var buf bytes.Buffer
+	if options.Output != "" {
+		w = &buf // the file is written after collecting (it may be in the collected packages, e.g. go:generate)
+	}
+	e, err := newEmitter(w)
+	if err != nil {
+		log.Fatalf("!! %+v", err)
+	}
 
-		if stat.IsDir() {
-			if err := runDir(fset, filename); err != nil {
+	failed := false // load errors, strict errors and conflict errors exit with 1 (after the output is written)
+	for _, filename := range flag.Args() {
+		if filename == "-" {
+			if err := runStdin(e); err != nil {
 				log.Printf("!! %+v", err)
+				failed = true
 			}
-		} else {
-			if err := runFile(fset, filename); err != nil {
+			continue
+		}
+
+		pkgs, err := commentof.LoadContext(ctx, []string{filename}, commentofOptions()...)
+		for _, p := range pkgs {
+			if err := encode(e, p); err != nil {
 				log.Printf("!! %+v", err)
+				failed = true
 			}
 		}
+		if err != nil {
+			log.Printf("!! %+v", err)
+			failed = true
+		}
 	}
-}
 
-func runDir(fset *token.FileSet, dirname string) error {
-	filter := func(info fs.FileInfo) bool {
-		return !strings.HasSuffix(info.Name(), "_test.go")
+	if err := e.Close(); err != nil {
+		log.Printf("!! %+v", err)
+		failed = true
 	}
-	if options.IncludeTestFile {
-		filter = nil
+	if options.Output != "" {
+		if err := writeFile(options.Output, buf.Bytes()); err != nil {
+			log.Fatalf("!! %+v", err)
+		}
 	}
-	tree, err := parser.ParseDir(fset, dirname, filter, parser.ParseComments)
-	if err != nil {
-		return fmt.Errorf("parse dir: %w", err)
+	if failed {
+		stop()
+		os.Exit(1)
 	}
+}
 
-	names := make([]string, 0, len(tree))
-	for name := range tree {
-		names = append(names, name)
+// splitSubcommand returns the subcommand and the rest of the arguments, or an empty string if the first argument is not a subcommand.
+// The directory having the same name as a subcommand is a package, not the subcommand (e.g. go-commentof cache documents ./cache).
+func splitSubcommand(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "", args
 	}
-	sort.Strings(names)
-
-	for _, name := range names {
-		p := tree[name]
-		result, err := commentof.Package(fset, p, commentof.WithIncludeUnexported(options.IncludeUnexported))
-		if err != nil {
-			return fmt.Errorf("collect: dir=%s, name=%s, %w", dirname, name, err)
+	for _, name := range subcommands {
+		if args[0] != name {
+			continue
 		}
-
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "	")
-		if err := enc.Encode(result); err != nil {
-			return fmt.Errorf("encode json: %w", err)
+		if info, err := os.Stat(name); err == nil && info.IsDir() {
+			return "", args
 		}
+		return name, args[1:]
 	}
-	return nil
+	return "", args
 }
 
-func runFile(fset *token.FileSet, filename string) error {
-	tree, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
-	if err != nil {
-		return fmt.Errorf("parse file: %w", err)
+func commentofOptions() []commentof.Option {
+	opts := []commentof.Option{
+		commentof.WithIncludeUnexported(options.IncludeUnexported),
+		commentof.WithIncludeConstants(includeConstants()),
+		commentof.WithResolveTypeDefs(options.ResolveTypeDefs),
+		commentof.WithDetectImplementations(options.Implements),
+		commentof.WithTypeCheck(typeCheckMode),
+		commentof.WithConflictPolicy(conflictPolicy),
+		commentof.WithStrict(options.Strict),
+		commentof.WithIncludeTestFile(options.IncludeTestFile),
+		commentof.WithWorkers(options.Workers),
 	}
-
-	result, err := commentof.File(fset, tree, commentof.WithIncludeUnexported(options.IncludeUnexported))
-	if err != nil {
-		return fmt.Errorf("collect: file=%s, %w", filename, err)
+	if overlay != nil {
+		opts = append(opts, commentof.WithOverlay(overlay))
 	}
-
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "	")
-	if err := enc.Encode(result); err != nil {
-		return fmt.Errorf("encode json: %w", err)
+	if options.Cache {
+		dir := options.CacheDir
+		if dir == "" {
+			d, err := cache.DefaultDir()
+			if err != nil {
+				log.Fatalf("!! %+v", err)
+			}
+			dir = d
+		}
+		opts = append(opts, commentof.WithCache(cache.New(dir)))
 	}
-	return nil
-}
-
-func runModulePackage(fset *token.FileSet, pkgpath string) error {
-	goModFile, err := goMod()
-	if err != nil {
-		return err
+	if options.GOOS != "" || options.GOARCH != "" || options.Tags != "" {
+		goos, goarch := options.GOOS, options.GOARCH
+		if goos == "" {
+			goos = runtime.GOOS
+		}
+		if goarch == "" {
+			goarch = runtime.GOARCH
+		}
+		var tags []string
+		if options.Tags != "" {
+			tags = strings.Split(options.Tags, ",")
+		}
+		opts = append(opts, commentof.WithPlatform(goos, goarch, tags...))
 	}
+	return opts
+}
 
-	// Try to download dependencies that are not in the module cache in order to
-	// to show their documentation.
-	// This may fail if module downloading is disallowed (GOPROXY=off) or due to
-	// limited connectivity, in which case we print errors to stderr and show
-	// documentation only for packages that are available.
-	fillModuleCache(os.Stderr, goModFile)
-
-	mods, err := buildList(goModFile)
-	if err != nil {
-		return err
+// includeConstants reports whether the constants are collected.
+// The json formats include them only if -include-constants is set (the output of the older versions has no constants).
+func includeConstants() bool {
+	if options.IncludeConstants || subcommand != "" || options.Template != "" {
+		return true
 	}
-
-	for _, mod := range mods {
-		if strings.HasPrefix(pkgpath, mod.Path) {
-			dirname := strings.Replace(pkgpath, mod.Path, mod.Dir, 1)
-			return runDir(fset, dirname)
-		}
+	switch options.Format {
+	case "", "json", "json-array", "json-object", "ndjson":
+		return false
+	default:
+		return true
 	}
-	return fmt.Errorf("package %s is not found", pkgpath)
 }
 
-// from: golang.org/x/tools/cmd/godoc/main.go
-
-// goMod returns the go env GOMOD value in the current directory
-// by invoking the go command.
-//
-// GOMOD is documented at https://golang.org/cmd/go/#hdr-Environment_variables:
-//
-//	The absolute path to the go.mod of the main module,
-//	or the empty string if not using modules.
-func goMod() (string, error) {
-	out, err := exec.Command("go", "env", "-json", "GOMOD").Output()
-	if ee := (*exec.ExitError)(nil); errors.As(err, &ee) {
-		return "", fmt.Errorf("go command exited unsuccessfully: %v\n%s", ee.ProcessState.String(), ee.Stderr)
-	} else if err != nil {
-		return "", err
+func newEmitter(w io.Writer) (emit.Emitter, error) {
+	if options.Template != "" {
+		return emit.NewTemplate(w, options.Template)
 	}
-	var env struct {
-		GoMod string
+	if options.Format == "openapi" && options.Symbols != "" {
+		return emit.NewOpenAPI(w, strings.Split(options.Symbols, ",")), nil
 	}
-	err = json.Unmarshal(out, &env)
-	if err != nil {
-		return "", err
+	if options.Format == "gocode" {
+		return emit.NewGoCode(w, options.GoCodePackage, options.GoCodeVar), nil
 	}
-	return env.GoMod, nil
+	if (options.Format == "proto" || options.Format == "graphql") && options.TypeMap != "" {
+		typemap, err := emit.LoadTypeMap(options.TypeMap)
+		if err != nil {
+			return nil, err
+		}
+		if options.Format == "proto" {
+			return emit.NewProto(w, typemap), nil
+		}
+		return emit.NewGraphQL(w, typemap), nil
+	}
+	return emit.New(options.Format, w)
 }
 
-// fillModuleCache does a best-effort attempt to fill the module cache
-// with all dependencies of the main module in the current directory
-// by invoking the go command. Module download logs are streamed to w.
-// If there are any problems encountered, they are also written to w.
-// It should only be used in module mode, when vendor mode isn't on.
-//
-// See https://golang.org/cmd/go/#hdr-Download_modules_to_local_cache.
-func fillModuleCache(w io.Writer, goMod string) {
-	if goMod == os.DevNull {
-		// No module requirements, nothing to do.
-		return
+func runStdin(e emit.Emitter) error {
+	src, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("read stdin: %w", err)
 	}
 
-	cmd := exec.Command("go", "mod", "download", "-json")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = w
-	err := cmd.Run()
-	if ee := (*exec.ExitError)(nil); errors.As(err, &ee) && ee.ExitCode() == 1 {
-		// Exit code 1 from this command means there were some
-		// non-empty Error values in the output. Print them to w.
-		fmt.Fprintf(w, "documentation for some packages is not shown:\n")
-		for dec := json.NewDecoder(&out); ; {
-			var m struct {
-				Path    string // Module path.
-				Version string // Module version.
-				Error   string // Error loading module.
-			}
-			err := dec.Decode(&m)
-			if err == io.EOF {
-				break
-			} else if err != nil {
-				fmt.Fprintf(w, "error decoding JSON object from go mod download -json: %v\n", err)
-				continue
-			}
-			if m.Error == "" {
-				continue
-			}
-			fmt.Fprintf(w, "\tmodule %s@%s is not in the module cache and there was a problem downloading it: %s\n", m.Path, m.Version, m.Error)
-		}
-	} else if err != nil {
-		fmt.Fprintf(w, "there was a problem filling module cache: %v\n", err)
+	result, err := commentof.Source(options.StdinFilename, src, commentofOptions()...)
+	if err != nil {
+		return fmt.Errorf("collect: stdin, %w", err)
 	}
+	return encode(e, result)
 }
 
-type mod struct {
-	Path string // Module path.
-	Dir  string // Directory holding files for this module, if any.
+func encode(e emit.Emitter, result *collect.Package) error {
+	printDiagnostics(result.Diagnostics)
+	return e.Emit(result)
 }
 
-// buildList determines the build list in the current directory
-// by invoking the go command. It should only be used in module mode,
-// when vendor mode isn't on.
-//
-// See https://golang.org/cmd/go/#hdr-The_main_module_and_the_build_list.
-func buildList(goMod string) ([]mod, error) {
-	if goMod == os.DevNull {
-		// Empty build list.
-		return nil, nil
-	}
-
-	out, err := exec.Command("go", "list", "-m", "-json", "all").Output()
-	if ee := (*exec.ExitError)(nil); errors.As(err, &ee) {
-		return nil, fmt.Errorf("go command exited unsuccessfully: %v\n%s", ee.ProcessState.String(), ee.Stderr)
-	} else if err != nil {
-		return nil, err
-	}
-	var mods []mod
-	for dec := json.NewDecoder(bytes.NewReader(out)); ; {
-		var m mod
-		err := dec.Decode(&m)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, err
+func printDiagnostics(diagnostics []*collect.Diagnostic) {
+	if options.DiagnosticsJSON {
+		enc := json.NewEncoder(os.Stderr)
+		for _, d := range diagnostics {
+			if err := enc.Encode(d); err != nil {
+				log.Printf("!! %+v", err)
+			}
 		}
-		mods = append(mods, m)
+		return
+	}
+	for _, d := range diagnostics {
+		fmt.Fprintln(os.Stderr, d.Error())
 	}
-	return mods, nil
 }
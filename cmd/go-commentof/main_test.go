@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitSubcommand(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "cache"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "html"), nil, 0o644); err != nil { // not a directory
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cases := []struct {
+		name     string
+		args     []string
+		wantName string
+		wantArgs []string
+	}{
+		{name: "no args", args: []string{}, wantArgs: []string{}},
+		{name: "packages", args: []string{"./..."}, wantArgs: []string{"./..."}},
+		{name: "serve", args: []string{"serve", "-addr", ":0", "./..."}, wantName: "serve", wantArgs: []string{"-addr", ":0", "./..."}},
+		{name: "html with the file of the same name", args: []string{"html", "-o", "site", "./..."}, wantName: "html", wantArgs: []string{"-o", "site", "./..."}},
+		{name: "the directory of the same name", args: []string{"cache"}, wantArgs: []string{"cache"}},
+		{name: "the directory of the same name, with ./", args: []string{"./cache"}, wantArgs: []string{"./cache"}},
+		{name: "not the first argument", args: []string{"-o", "x.json", "serve"}, wantArgs: []string{"-o", "x.json", "serve"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			name, args := splitSubcommand(c.args)
+			if name != c.wantName {
+				t.Errorf("subcommand: want %q, but got %q", c.wantName, name)
+			}
+			if !reflect.DeepEqual(args, c.wantArgs) {
+				t.Errorf("args: want %q, but got %q", c.wantArgs, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// overlay is the contents of the files replaced by -overlay (a nil value means that the file is deleted).
+var overlay map[string][]byte
+
+// loadOverlay reads the overlay file, the same format as go build -overlay.
+//
+//	{"Replace": {"/path/to/foo.go": "/tmp/unsaved/foo.go", "/path/to/deleted.go": ""}}
+func loadOverlay(filename string) (map[string][]byte, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		Replace map[string]string
+	}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("decode overlay %s: %w", filename, err)
+	}
+
+	m := make(map[string][]byte, len(data.Replace))
+	for path, replaced := range data.Replace {
+		abspath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if replaced == "" {
+			m[abspath] = nil
+			continue
+		}
+		content, err := os.ReadFile(replaced)
+		if err != nil {
+			return nil, fmt.Errorf("read overlay: %w", err)
+		}
+		m[abspath] = content
+	}
+	return m, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/podhmo/commentof/collect"
+	"github.com/podhmo/commentof/emit"
+)
+
+// runServe runs the serve subcommand, serving the collected packages as JSON.
+// The packages are re-collected when the files are changed (see watch).
+//
+//   - GET /packages: the list of the packages
+//   - GET /packages/{path}: the package (path is the import path, or the directory)
+//   - GET /symbols/{path}/{id}: the symbol of the package (e.g. /symbols/example.com/foo/S.Nested, see collect.Package.Lookup)
+//   - GET /search?q={text}&kind={kind}: the symbols whose ids, docs or comments contain the text (case-insensitive)
+func runServe(ctx context.Context, args []string) error {
+	s := &server{}
+	ready := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- watch(ctx, args, func(groups [][]*collect.Package, changed map[string]bool) error {
+			s.update(groups, changed)
+			if changed == nil {
+				close(ready)
+			}
+			return nil
+		})
+	}()
+	select {
+	case <-ready:
+	case err := <-errc:
+		return err
+	}
+
+	srv := &http.Server{Addr: options.Addr, Handler: s}
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Printf("!! %+v", err)
+		}
+	}()
+	log.Printf("listening on %s", options.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+type server struct {
+	mu       sync.RWMutex
+	keys     []string // sorted
+	packages map[string]*collect.Package
+}
+
+func (s *server) update(groups [][]*collect.Package, changed map[string]bool) {
+	packages := map[string]*collect.Package{}
+	for _, pkgs := range groups {
+		for _, p := range pkgs {
+			if changed == nil || changed[p.Dir] {
+				printDiagnostics(p.Diagnostics)
+			}
+			packages[emit.Key(p)] = p
+		}
+	}
+	keys := make([]string, 0, len(packages))
+	for k := range packages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.packages = packages
+}
+
+type packageSummary struct {
+	Key        string `json:"key"` // the path of /packages/{path}
+	Name       string `json:"name"`
+	ImportPath string `json:"importpath,omitempty"`
+	Symbols    int    `json:"symbols"`
+}
+
+// hit is the result of /search.
+type hit struct {
+	Package string `json:"package"`
+	ID      string `json:"id"`
+	Kind    string `json:"kind"` // type, interface, func, const, field, method, param or return
+	Doc     string `json:"doc"`
+	Comment string `json:"comment"`
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	s.mu.RLock()
+	keys, packages := s.keys, s.packages
+	s.mu.RUnlock()
+
+	path := r.URL.Path
+	switch {
+	case path == "/packages" || path == "/packages/":
+		r := make([]*packageSummary, 0, len(keys))
+		for _, k := range keys {
+			p := packages[k]
+			r = append(r, &packageSummary{Key: k, Name: p.Name, ImportPath: p.ImportPath, Symbols: len(p.Names)})
+		}
+		writeJSON(w, r)
+	case strings.HasPrefix(path, "/packages/"):
+		key := strings.TrimPrefix(path, "/packages/")
+		p, ok := packages[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("package %s is not found", key))
+			return
+		}
+		writeJSON(w, p)
+	case strings.HasPrefix(path, "/symbols/"):
+		rest := strings.TrimPrefix(path, "/symbols/")
+		i := strings.LastIndex(rest, "/")
+		if i < 0 {
+			writeError(w, http.StatusNotFound, fmt.Errorf("usage: /symbols/{path}/{id}"))
+			return
+		}
+		key, id := rest[:i], rest[i+1:]
+		p, ok := packages[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("package %s is not found", key))
+			return
+		}
+		v := p.Lookup(id)
+		if v == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("symbol %s is not found in %s", id, key))
+			return
+		}
+		writeJSON(w, v)
+	case path == "/search":
+		q := strings.ToLower(r.URL.Query().Get("q"))
+		if q == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("q is required"))
+			return
+		}
+		kind := r.URL.Query().Get("kind")
+		hits := []*hit{}
+		for _, k := range keys {
+			emit.Walk(packages[k], func(e *emit.Entry) {
+				if kind != "" && e.Kind != kind {
+					return
+				}
+				if strings.Contains(strings.ToLower(e.ID), q) || strings.Contains(strings.ToLower(e.Doc), q) || strings.Contains(strings.ToLower(e.Comment), q) {
+					hits = append(hits, &hit{Package: k, ID: e.ID, Kind: e.Kind, Doc: e.Doc, Comment: e.Comment})
+				}
+			})
+		}
+		writeJSON(w, hits)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s is not found", path))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("!! %+v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": err.Error()}); err != nil {
+		log.Printf("!! %+v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/podhmo/commentof"
+	"github.com/podhmo/commentof/cache"
+	"github.com/podhmo/commentof/collect"
+	"github.com/podhmo/commentof/emit"
+)
+
+// runWatch collects the packages, and re-collects the packages whenever their directories are changed (polling).
+// The files not touched are not parsed again (the collected files are cached in memory).
+// The local patterns (e.g. ./...) are expanded again when a package directory is added or removed.
+func runWatch(ctx context.Context, args []string) error {
+	return watch(ctx, args, emitPackages)
+}
+
+// watchTarget is the packages loaded from an argument of watch.
+type watchTarget struct {
+	arg  string
+	root string          // the root directory of the local pattern (e.g. . for ./...), or empty
+	tree map[string]bool // the directories having go files under root
+	pkgs []*collect.Package
+}
+
+// watch loads the packages for each argument, and calls update with them (changed is nil at the first time).
+// Then it calls update again whenever the directories of the packages are changed, until ctx is done.
+// Only the changed directories are collected again, and the pattern is expanded again only when a directory is added or removed.
+func watch(ctx context.Context, args []string, update func(groups [][]*collect.Package, changed map[string]bool) error) error {
+	opts := commentofOptions()
+	if !options.Cache {
+		opts = append(opts, commentof.WithCache(cache.NewMemory()))
+	}
+
+	targets := make([]*watchTarget, len(args))
+	for i, arg := range args {
+		if arg == "-" {
+			return fmt.Errorf("stdin cannot be watched")
+		}
+		t := &watchTarget{arg: arg, root: patternRoot(arg)}
+		if t.root != "" {
+			t.tree = goDirs(t.root)
+		}
+		pkgs, err := commentof.LoadContext(ctx, []string{arg}, opts...)
+		if err != nil {
+			log.Printf("!! %+v", err)
+		}
+		t.pkgs = pkgs
+		targets[i] = t
+	}
+	if err := update(groupsOf(targets), nil); err != nil {
+		return err
+	}
+
+	snapshot := snapshotDirs(targets)
+	ticker := time.NewTicker(options.WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		changed := changedDirs(snapshot, snapshotDirs(targets))
+		expanded := map[*watchTarget]bool{}
+		for _, t := range targets {
+			if t.root == "" {
+				continue
+			}
+			tree := goDirs(t.root)
+			if sameDirs(t.tree, tree) {
+				continue
+			}
+			pkgs, err := commentof.LoadContext(ctx, []string{t.arg}, opts...) // a package directory is added or removed
+			if err != nil {
+				log.Printf("!! %+v", err)
+				continue // keep the previous result
+			}
+			for dir := range addedDirs(t.pkgs, pkgs) {
+				changed[dir] = true
+			}
+			t.pkgs, t.tree = pkgs, tree
+			expanded[t] = true
+		}
+		if len(changed) == 0 && len(expanded) == 0 {
+			continue
+		}
+
+		for _, t := range targets {
+			if expanded[t] {
+				continue // already collected with the pattern
+			}
+			if err := t.reload(ctx, changed, opts); err != nil {
+				log.Printf("!! %+v", err)
+			}
+		}
+		if err := update(groupsOf(targets), changed); err != nil {
+			log.Printf("!! %+v", err)
+		}
+		snapshot = snapshotDirs(targets)
+	}
+}
+
+// reload collects the packages in the changed directories again.
+// The packages of a directory or a file argument are collected from the argument itself.
+func (t *watchTarget) reload(ctx context.Context, changed map[string]bool, opts []commentof.Option) error {
+	var dirs []string
+	for _, p := range t.pkgs {
+		if changed[p.Dir] && (len(dirs) == 0 || dirs[len(dirs)-1] != p.Dir) {
+			dirs = append(dirs, p.Dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+	if !strings.Contains(t.arg, "...") && t.arg != "std" && t.arg != "cmd" && t.arg != "all" {
+		pkgs, err := commentof.LoadContext(ctx, []string{t.arg}, opts...)
+		if err != nil {
+			return err // keep the previous result
+		}
+		t.pkgs = pkgs
+		return nil
+	}
+
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); err != nil {
+			t.pkgs = replacePackages(t.pkgs, dir, nil) // removed
+			continue
+		}
+		pkgs, err := commentof.LoadContext(ctx, []string{dirArg(dir)}, opts...)
+		if err != nil {
+			return err // keep the previous result
+		}
+		t.pkgs = replacePackages(t.pkgs, dir, pkgs)
+	}
+	return nil
+}
+
+func groupsOf(targets []*watchTarget) [][]*collect.Package {
+	groups := make([][]*collect.Package, len(targets)) // packages for each argument
+	for i, t := range targets {
+		groups[i] = t.pkgs
+	}
+	return groups
+}
+
+// replacePackages returns the packages, replacing the packages in the directory with pkgs (at the position of the first one).
+// The given slice is not modified (it may be held by the callback of watch).
+func replacePackages(current []*collect.Package, dir string, pkgs []*collect.Package) []*collect.Package {
+	r := make([]*collect.Package, 0, len(current)+len(pkgs))
+	replaced := false
+	for _, p := range current {
+		if p.Dir != dir {
+			r = append(r, p)
+			continue
+		}
+		if !replaced {
+			r = append(r, pkgs...)
+			replaced = true
+		}
+	}
+	if !replaced {
+		r = append(r, pkgs...)
+	}
+	return r
+}
+
+// addedDirs returns the directories of the packages which are not in prev.
+func addedDirs(prev []*collect.Package, pkgs []*collect.Package) map[string]bool {
+	seen := make(map[string]bool, len(prev))
+	for _, p := range prev {
+		seen[p.Dir] = true
+	}
+	added := map[string]bool{}
+	for _, p := range pkgs {
+		if !seen[p.Dir] {
+			added[p.Dir] = true
+		}
+	}
+	return added
+}
+
+// dirArg returns the directory as an argument of LoadContext (a relative path is not a package pattern, e.g. ./foo for foo).
+func dirArg(dir string) string {
+	if filepath.IsAbs(dir) || strings.HasPrefix(dir, ".") {
+		return dir
+	}
+	return "." + string(filepath.Separator) + dir
+}
+
+// patternRoot returns the root directory of the local pattern (e.g. . for ./..., and ./foo for ./foo/...), or an empty string.
+// The patterns of the import paths (e.g. net/...) are not local.
+func patternRoot(arg string) string {
+	i := strings.Index(arg, "...")
+	if i < 0 || !(strings.HasPrefix(arg, ".") || filepath.IsAbs(arg)) {
+		return ""
+	}
+	prefix := arg[:i]
+	if !strings.HasSuffix(prefix, "/") {
+		prefix = filepath.Dir(prefix) // ./foo... matches ./foo and ./foobar
+	}
+	return filepath.Clean(prefix)
+}
+
+// goDirs returns the directories having go files under root, skipping the directories ignored by the go command (testdata, vendor, _foo and .foo).
+func goDirs(root string) map[string]bool {
+	dirs := map[string]bool{}
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".go") {
+			dirs[filepath.Dir(path)] = true
+		}
+		return nil
+	})
+	return dirs
+}
+
+func sameDirs(x, y map[string]bool) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for dir := range x {
+		if !y[dir] {
+			return false
+		}
+	}
+	return true
+}
+
+// changedDirs returns the directories whose states are changed (including the removed ones).
+func changedDirs(prev, current map[string]string) map[string]bool {
+	changed := map[string]bool{}
+	for dir, state := range current {
+		if prev[dir] != state {
+			changed[dir] = true
+		}
+	}
+	for dir := range prev {
+		if _, ok := current[dir]; !ok {
+			changed[dir] = true
+		}
+	}
+	return changed
+}
+
+// emitPackages rewrites the output file with all packages (in the format of -format or -template), or writes the changed packages to stdout as NDJSON.
+// If changed is nil, all packages are emitted.
+func emitPackages(groups [][]*collect.Package, changed map[string]bool) error {
+	if options.Output == "" {
+		e, _ := emit.New("ndjson", os.Stdout)
+		for _, pkgs := range groups {
+			for _, p := range pkgs {
+				if changed != nil && !changed[p.Dir] {
+					continue
+				}
+				if err := encode(e, p); err != nil {
+					return err
+				}
+			}
+		}
+		return e.Close()
+	}
+
+	var buf bytes.Buffer
+	e, err := newEmitter(&buf)
+	if err != nil {
+		return err
+	}
+	for _, pkgs := range groups {
+		for _, p := range pkgs {
+			if changed == nil || changed[p.Dir] {
+				printDiagnostics(p.Diagnostics)
+			}
+			if err := e.Emit(p); err != nil {
+				return err
+			}
+		}
+	}
+	if err := e.Close(); err != nil {
+		return err
+	}
+	return writeFile(options.Output, buf.Bytes())
+}
+
+// writeFile writes the file atomically (readers never see the half-written file).
+func writeFile(filename string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// snapshotDirs returns the state (names, sizes and modification times of the go files) of the package directories.
+func snapshotDirs(targets []*watchTarget) map[string]string {
+	snapshot := map[string]string{}
+	for _, t := range targets {
+		for _, p := range t.pkgs {
+			if _, ok := snapshot[p.Dir]; ok {
+				continue
+			}
+			snapshot[p.Dir] = dirState(p.Dir)
+		}
+	}
+	return snapshot
+}
+
+func dirState(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "" // removed
+	}
+	var lines []string
+	for _, d := range entries {
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".go") {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %d", d.Name(), info.Size(), info.ModTime().UnixNano()))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+func TestPatternRoot(t *testing.T) {
+	cases := []struct {
+		arg  string
+		want string
+	}{
+		{arg: "./...", want: "."},
+		{arg: "./foo/...", want: "foo"},
+		{arg: "./foo...", want: "."},
+		{arg: "/src/foo/...", want: "/src/foo"},
+		{arg: "./foo", want: ""},        // a directory
+		{arg: "net/...", want: ""},      // not local
+		{arg: "std", want: ""},          // not local
+		{arg: "./foo/bar.go", want: ""}, // a file
+	}
+	for _, c := range cases {
+		if got := patternRoot(c.arg); got != c.want {
+			t.Errorf("patternRoot(%q): want %q, but got %q", c.arg, c.want, got)
+		}
+	}
+}
+
+func TestChangedDirs(t *testing.T) {
+	cases := []struct {
+		name    string
+		prev    map[string]string
+		current map[string]string
+		want    map[string]bool
+	}{
+		{name: "unchanged", prev: map[string]string{"a": "x", "b": "y"}, current: map[string]string{"a": "x", "b": "y"}, want: map[string]bool{}},
+		{name: "modified", prev: map[string]string{"a": "x", "b": "y"}, current: map[string]string{"a": "x", "b": "z"}, want: map[string]bool{"b": true}},
+		{name: "added", prev: map[string]string{"a": "x"}, current: map[string]string{"a": "x", "b": "y"}, want: map[string]bool{"b": true}},
+		{name: "removed", prev: map[string]string{"a": "x", "b": "y"}, current: map[string]string{"a": "x"}, want: map[string]bool{"b": true}},
+		{name: "emptied", prev: map[string]string{"a": "x"}, current: map[string]string{"a": ""}, want: map[string]bool{"a": true}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := changedDirs(c.prev, c.current); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want %v, but got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestReplacePackages(t *testing.T) {
+	pkg := func(dir, name string) *collect.Package { return &collect.Package{Dir: dir, Name: name} }
+	names := func(pkgs []*collect.Package) []string {
+		r := make([]string, len(pkgs))
+		for i, p := range pkgs {
+			r[i] = p.Dir + ":" + p.Name
+		}
+		return r
+	}
+
+	cases := []struct {
+		name string
+		dir  string
+		pkgs []*collect.Package
+		want []string
+	}{
+		{name: "replace with the test package", dir: "c", pkgs: []*collect.Package{pkg("c", "c"), pkg("c", "c_test")}, want: []string{"a:a", "b:b", "b:b_test", "c:c", "c:c_test"}},
+		{name: "replace the package and the test package", dir: "b", pkgs: []*collect.Package{pkg("b", "b2")}, want: []string{"a:a", "b:b2", "c:c"}},
+		{name: "remove", dir: "b", want: []string{"a:a", "c:c"}},
+		{name: "add", dir: "d", pkgs: []*collect.Package{pkg("d", "d")}, want: []string{"a:a", "b:b", "b:b_test", "c:c", "d:d"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			current := []*collect.Package{pkg("a", "a"), pkg("b", "b"), pkg("b", "b_test"), pkg("c", "c")}
+			before := names(current)
+
+			got := replacePackages(current, c.dir, c.pkgs)
+			if want := c.want; !reflect.DeepEqual(names(got), want) {
+				t.Errorf("want %v, but got %v", want, names(got))
+			}
+			if !reflect.DeepEqual(names(current), before) {
+				t.Errorf("the given slice is modified: %v", names(current))
+			}
+		})
+	}
+}
+
+func TestGoDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"main.go",
+		"foo/foo.go",
+		"foo/bar/bar.go",
+		"foo/testdata/x.go",
+		"vendor/v/v.go",
+		"_ignored/x.go",
+		".hidden/x.go",
+		"empty/README.md",
+	} {
+		filename := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte("package x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := goDirs(root)
+	want := map[string]bool{
+		root:                              true,
+		filepath.Join(root, "foo"):        true,
+		filepath.Join(root, "foo", "bar"): true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got %v", want, got)
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "baz"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if !sameDirs(got, goDirs(root)) {
+		t.Errorf("a directory without go files is not a package directory")
+	}
+	if err := os.WriteFile(filepath.Join(root, "baz", "baz.go"), []byte("package baz\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if sameDirs(got, goDirs(root)) {
+		t.Errorf("the added package directory is not detected")
+	}
+}
+
+func TestAddedDirs(t *testing.T) {
+	prev := []*collect.Package{{Dir: "a"}, {Dir: "b"}, {Dir: "b"}}
+	pkgs := []*collect.Package{{Dir: "a"}, {Dir: "c"}, {Dir: "c"}}
+	if got, want := addedDirs(prev, pkgs), map[string]bool{"c": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got %v", want, got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, src string) {
+		t.Helper()
+		filename := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module m\n\ngo 1.18\n")
+	write("a/a.go", "package a\n\n// F is a function.\nfunc F() {}\n")
+	write("b/b.go", "package b\n\n// G is a function.\nfunc G() {}\n")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func(interval time.Duration) { options.WatchInterval = interval }(options.WatchInterval)
+	options.WatchInterval = 10 * time.Millisecond
+
+	type result struct {
+		pkgs    []string
+		changed []string
+	}
+	results := make(chan result)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- watch(ctx, []string{"./..."}, func(groups [][]*collect.Package, changed map[string]bool) error {
+			var r result
+			for _, p := range groups[0] {
+				r.pkgs = append(r.pkgs, p.Name)
+			}
+			for dir := range changed {
+				rel, _ := filepath.Rel(root, dir)
+				r.changed = append(r.changed, rel)
+			}
+			sort.Strings(r.changed)
+			results <- r
+			return nil
+		})
+	}()
+	next := func() result {
+		t.Helper()
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout")
+			return result{}
+		}
+	}
+
+	cases := []struct {
+		name   string
+		action func()
+		want   result
+	}{
+		{name: "first load", action: func() {}, want: result{pkgs: []string{"a", "b"}}},
+		{name: "modified", action: func() { write("b/b.go", "package b\n\n// G is a function (changed).\nfunc G() {}\n") }, want: result{pkgs: []string{"a", "b"}, changed: []string{"b"}}},
+		{name: "added", action: func() { write("c/c.go", "package c\n\n// H is a function.\nfunc H() {}\n") }, want: result{pkgs: []string{"a", "b", "c"}, changed: []string{"c"}}},
+		{name: "removed", action: func() { os.RemoveAll(filepath.Join(root, "a")) }, want: result{pkgs: []string{"b", "c"}, changed: []string{"a"}}},
+	}
+	for _, c := range cases {
+		c.action()
+		if got := next(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: want %+v, but got %+v", c.name, c.want, got)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %+v", err)
+	}
+}
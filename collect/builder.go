@@ -1,8 +1,13 @@
 package collect
 
 import (
+	"fmt"
 	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
 	"strings"
+	"unicode"
 )
 
 type PackageBuilder struct {
@@ -10,30 +15,372 @@ type PackageBuilder struct {
 
 	EnableMergeMethod bool
 	IgnoreExported    bool
+	ResolveTypeDefs   bool // inherit the fields of in-package types from aliases and defined types
+
+	DetectImplementations bool           // relate the types and the interfaces
+	TypesPackage          *types.Package // type-checked package (optional)
+
+	Platform       *Platform // if set, the files not built on the platform are skipped
+	ConflictPolicy ConflictPolicy
+
+	Fset   *token.FileSet    // for reporting positions (optional)
+	Strict bool              // if true, the diagnostics (warning or error) are returned as errors
+	Report func(*Diagnostic) // if set, called for each diagnostic (the diagnostics are also stored in the package)
+
+	symbols map[string]*symbol // the toplevel definitions added so far (see symbolKey)
 }
 
-func (b *PackageBuilder) AddFile(f *File, filename string) {
-	p := b.Package
+// ConflictPolicy decides what happens when the same symbol is defined twice (on the same platform).
+type ConflictPolicy int
+
+const (
+	ConflictKeepVariants ConflictPolicy = iota // keep all definitions (the later ones are kept as variants)
+	ConflictKeepFirst                          // keep the first definition
+	ConflictError                              // AddFile returns an error
+)
+
+// symbol is the toplevel definition of the file (*Object, *Func or *Const).
+type symbol struct {
+	id       string
+	kind     string // type, interface, func, method or const
+	pos      token.Pos
+	platform string
+	value    interface{}
+}
+
+// symbolKey returns the key of the definition in the package. The methods of T and *T share the key (e.g. Ob#Name for *Ob#Name).
+func symbolKey(id string) string {
+	if strings.Contains(id, "#") {
+		return strings.TrimPrefix(id, "*")
+	}
+	return id
+}
+
+// symbolsOf returns the definitions of the id in the file, in the source order (e.g. type Foo and func Foo).
+func symbolsOf(f *File, id string) []*symbol {
+	var r []*symbol
+	if s, ok := f.Types[id]; ok {
+		r = append(r, &symbol{id: id, kind: "type", pos: s.Pos, value: s})
+	}
+	if s, ok := f.Interfaces[id]; ok {
+		r = append(r, &symbol{id: id, kind: "interface", pos: s.Pos, value: s})
+	}
+	if s, ok := f.Functions[id]; ok {
+		kind := "func"
+		if strings.Contains(id, "#") {
+			kind = "method"
+		}
+		r = append(r, &symbol{id: id, kind: kind, pos: s.Pos, value: s})
+	}
+	if s, ok := f.Constants[id]; ok {
+		r = append(r, &symbol{id: id, kind: "const", pos: s.Pos, value: s})
+	}
+	sort.SliceStable(r, func(i, j int) bool { return r[i].pos < r[j].pos })
+	return r
+}
+
+// AddFile adds the collected file to the package.
+// The duplicate symbols (of any kind) are resolved by the conflict policy, and with ConflictError, the package is left unchanged.
+func (b *PackageBuilder) AddFile(f *File, filename string) error {
+	if b.Platform != nil && !b.Platform.Match(f) {
+		return nil
+	}
+	if b.symbols == nil {
+		b.symbols = map[string]*symbol{}
+	}
+
+	// the conflicts are resolved before the package is changed.
+	// the definitions for the other platforms are kept as variants (e.g. Open in open_linux.go and open_windows.go)
+	type addition struct {
+		sym       *symbol
+		variantOf *symbol // nil, if it is the first definition
+	}
+	var additions []addition
+	var diagnostics []*Diagnostic
+	platform := f.BuildCondition()
+	pending := map[string]*symbol{}
+	seen := map[string]bool{}
+	skipped := map[string]bool{}
+	dropped := map[string]*Diagnostic{} // the types of the file dropped by the conflicts -> the conflict (their methods are dropped too)
+	lookup := func(key string) (*symbol, bool) {
+		if s, ok := pending[key]; ok {
+			return s, true
+		}
+		s, ok := b.symbols[key]
+		return s, ok
+	}
 
+	var methods []string // resolved after their receivers
+	for _, id := range f.Names {
+		if seen[id] {
+			continue // the same name appears twice in the file (e.g. init)
+		}
+		seen[id] = true
+		if strings.Contains(id, "#") {
+			methods = append(methods, id)
+			continue
+		}
+
+		syms := symbolsOf(f, id)
+		if len(syms) == 0 {
+			continue // nested (e.g. S.Nested)
+		}
+		added := false
+		for _, s := range syms {
+			s.platform = platform
+			prev, ok := lookup(symbolKey(id))
+			if !ok {
+				pending[symbolKey(id)] = s
+				additions = append(additions, addition{sym: s})
+				added = true
+				continue
+			}
+
+			keep := true
+			if !(id == "init" && prev.kind == "func" && s.kind == "func") { // init functions can be defined multiple times
+				var d *Diagnostic
+				var err error
+				keep, d, err = b.resolveConflict(prev, s)
+				if err != nil {
+					b.report(d)
+					return err
+				}
+				if d != nil {
+					diagnostics = append(diagnostics, d)
+					if !keep && s.kind == "type" {
+						dropped[id] = d
+					}
+				}
+			}
+			if keep {
+				additions = append(additions, addition{sym: s, variantOf: prev})
+			}
+		}
+		if !added {
+			skipped[id] = true
+		}
+	}
+
+	for _, id := range methods {
+		syms := symbolsOf(f, id)
+		if len(syms) == 0 {
+			continue
+		}
+		s := syms[0]
+		s.platform = platform
+		owner := methodOwnerName(id)
+		if d, ok := dropped[owner]; ok {
+			d.Message += fmt.Sprintf(", and the method %s is dropped", id)
+			skipped[id] = true
+			continue
+		}
+		if o, ok := lookup(owner); ok && o.kind != "type" {
+			d, err := b.orphanMethod(o, s)
+			if err != nil {
+				b.report(d)
+				return err
+			}
+			diagnostics = append(diagnostics, d)
+			skipped[id] = true
+			continue
+		}
+
+		prev, ok := lookup(symbolKey(id))
+		if !ok {
+			pending[symbolKey(id)] = s
+			additions = append(additions, addition{sym: s})
+			continue
+		}
+		keep, d, err := b.resolveConflict(prev, s)
+		if err != nil {
+			b.report(d)
+			return err
+		}
+		if d != nil {
+			diagnostics = append(diagnostics, d)
+		}
+		if keep {
+			additions = append(additions, addition{sym: s, variantOf: prev})
+		}
+		skipped[id] = true
+	}
+	for _, d := range diagnostics {
+		if err := b.report(d); err != nil {
+			return err
+		}
+	}
+
+	p := b.Package
 	p.FileNames = append(p.FileNames, filename)
 	p.Files[filename] = f
+	p.Diagnostics = append(p.Diagnostics, f.Diagnostics...)
+	if f.Incomplete {
+		p.IncompleteFiles = append(p.IncompleteFiles, filename)
+	}
+	for _, a := range additions {
+		if a.variantOf != nil {
+			addVariant(a.variantOf, a.sym)
+			continue
+		}
+		b.symbols[symbolKey(a.sym.id)] = a.sym
+		addSymbol(p, a.sym)
+	}
+	p.Diagnostics = append(p.Diagnostics, diagnostics...)
+
+	named := make(map[string]bool, len(f.Names))
+	for _, name := range f.Names {
+		if named[name] || skipped[name] {
+			continue // the same name appears twice in the file (e.g. const Foo and type Foo)
+		}
+		if !strings.Contains(name, "#") && skipped[ownerName(name)] {
+			continue // the nested name of the skipped type (e.g. S.Nested); the methods of the variants are kept
+		}
+		named[name] = true
+		p.Names = append(p.Names, name)
+	}
+	return nil
+}
+
+func addSymbol(p *Package, s *symbol) {
+	switch v := s.value.(type) {
+	case *Object:
+		v.Platform = s.platform
+		if s.kind == "interface" {
+			p.Interfaces[s.id] = v
+		} else {
+			p.Types[s.id] = v
+		}
+	case *Func:
+		v.Platform = s.platform
+		p.Functions[s.id] = v
+	case *Const:
+		v.Platform = s.platform
+		p.Constants[s.id] = v
+	}
+}
+
+// addVariant adds the later definition as the variant of the first one (they are the same kind).
+func addVariant(first *symbol, s *symbol) {
+	switch v := s.value.(type) {
+	case *Object:
+		v.Platform = s.platform
+		ob := first.value.(*Object)
+		ob.Variants = append(ob.Variants, v)
+	case *Func:
+		v.Platform = s.platform
+		fn := first.value.(*Func)
+		fn.Variants = append(fn.Variants, v)
+	case *Const:
+		v.Platform = s.platform
+		c := first.value.(*Const)
+		c.Variants = append(c.Variants, v)
+	}
+}
+
+// resolveConflict decides whether the later definition of the symbol is kept as a variant, by the conflict policy.
+// The diagnostic of the duplicate is returned (and also as the error, with ConflictError).
+// The definitions of the same kind for the different platforms are always kept,
+// and the definitions of the different kinds cannot be variants (the first one is kept).
+func (b *PackageBuilder) resolveConflict(prev *symbol, s *symbol) (bool, *Diagnostic, error) {
+	sameKind := prev.kind == s.kind
+	if sameKind && prev.platform != s.platform {
+		return true, nil, nil
+	}
 
-	p.Names = append(p.Names, f.Names...)
-	for id, s := range f.Types {
-		p.Types[id] = s
+	d := &Diagnostic{
+		Pos:      s.pos,
+		Position: b.position(s.pos),
+		Related:  b.position(prev.pos),
+		Severity: SeverityWarning,
+		Code:     "duplicate-symbol",
+	}
+	switch {
+	case !sameKind:
+		d.Message = fmt.Sprintf("%s is defined as %s and %s (previous definition is at %s)", s.id, prev.kind, s.kind, b.position(prev.pos))
+	case prev.id != s.id: // methods of T and *T
+		d.Message = fmt.Sprintf("%s is defined twice, as %s and %s (previous definition is at %s)", s.id, prev.id, s.id, b.position(prev.pos))
+	default:
+		d.Message = fmt.Sprintf("%s is defined twice (previous definition is at %s)", s.id, b.position(prev.pos))
 	}
-	for id, s := range f.Interfaces {
-		p.Interfaces[id] = s
+	switch b.ConflictPolicy {
+	case ConflictError:
+		d.Severity = SeverityError
+		return false, d, d
+	case ConflictKeepFirst:
+		return false, d, nil
+	default:
+		return sameKind, d, nil
 	}
-	for id, s := range f.Functions {
-		p.Functions[id] = s
+}
+
+// orphanMethod returns the conflict of the method and its receiver defined as the other kind (e.g. func Hello and func (Hello) M).
+func (b *PackageBuilder) orphanMethod(owner *symbol, s *symbol) (*Diagnostic, error) {
+	d := &Diagnostic{
+		Pos:      s.pos,
+		Position: b.position(s.pos),
+		Related:  b.position(owner.pos),
+		Severity: SeverityWarning,
+		Code:     "duplicate-symbol",
+		Message:  fmt.Sprintf("%s is dropped, because %s is defined as %s (at %s)", s.id, owner.id, owner.kind, b.position(owner.pos)),
 	}
+	if b.ConflictPolicy == ConflictError {
+		d.Severity = SeverityError
+		return d, d
+	}
+	return d, nil
+}
+
+// methodOwnerName returns the name of the receiver type of the method (e.g. T for *T#M).
+func methodOwnerName(id string) string {
+	return strings.TrimPrefix(id[:strings.Index(id, "#")], "*")
+}
+
+// report calls the Report callback with the diagnostic. In strict mode, it is returned as an error.
+// The diagnostic is stored in the package by AddFile (after all conflicts are resolved).
+func (b *PackageBuilder) report(d *Diagnostic) error {
+	if b.Report != nil {
+		b.Report(d)
+	}
+	if b.Strict && d.Severity != SeverityInfo {
+		return d
+	}
+	return nil
+}
+
+func (b *PackageBuilder) position(pos token.Pos) string {
+	if b.Fset == nil || !pos.IsValid() {
+		return ""
+	}
+	return b.Fset.Position(pos).String()
+}
+
+// ownerName returns the name of the toplevel type of the nested name (e.g. S for S.Nested).
+func ownerName(name string) string {
+	for i, r := range name {
+		if !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return name[:i]
+		}
+	}
+	return name
 }
 
 func (b *PackageBuilder) Build() *Package {
 	if b.EnableMergeMethod {
 		mergeMethod(b.Package)
 	}
+	if b.ResolveTypeDefs {
+		resolveTypeDefs(b.Package)
+	}
+	if b.EnableMergeMethod {
+		computeMethodSets(b.Package)
+	}
+	if b.DetectImplementations {
+		if b.TypesPackage != nil {
+			detectImplementationsWithTypes(b.Package, b.TypesPackage)
+		} else if b.EnableMergeMethod {
+			detectImplementations(b.Package)
+		}
+	}
 	if b.IgnoreExported {
 		ignoreExported(b.Package)
 	}
@@ -47,9 +394,15 @@ func mergeMethod(p *Package) {
 			names = append(names, name)
 			continue
 		}
-		method := p.Functions[name]
+		method, ok := p.Functions[name]
+		if !ok {
+			continue
+		}
 		obName := strings.TrimPrefix(method.Recv, "*")
 		if ob, ok := p.Types[obName]; ok {
+			if _, dup := ob.Methods[method.Name]; dup { // the methods of T and *T are resolved by AddFile (see symbolKey)
+				continue
+			}
 			ob.MethodNames = append(ob.MethodNames, method.Name)
 			ob.Methods[method.Name] = method
 			delete(p.Functions, name)
@@ -58,6 +411,240 @@ func mergeMethod(p *Package) {
 	p.Names = names
 }
 
+// resolveTypeDefs copies the structure of in-package struct or interface types
+// to the aliases and defined types of them (e.g. type S2 S, type S3 = S).
+// The methods are inherited only by aliases, from the aliased type (see methodOwner).
+func resolveTypeDefs(p *Package) {
+	for _, name := range p.Names {
+		ob, ok := p.Types[name]
+		if !ok || ob.Underlying == "" {
+			continue
+		}
+		target := lookupTarget(p, ob)
+		if target == nil {
+			continue
+		}
+
+		ob.Target = target.Name
+		ob.Token = target.Token
+		ob.FieldNames = append(make([]string, 0, len(target.FieldNames)), target.FieldNames...)
+		ob.Fields = make(map[string]*Field, len(target.Fields))
+		for id, field := range target.Fields {
+			ob.Fields[id] = field
+		}
+
+		if owner := methodOwner(p, ob); owner != nil {
+			if ob.Methods == nil {
+				ob.Methods = map[string]*Func{}
+			}
+			for _, id := range owner.MethodNames {
+				if _, ok := ob.Methods[id]; ok {
+					continue
+				}
+				ob.MethodNames = append(ob.MethodNames, id)
+				ob.Methods[id] = owner.Methods[id]
+			}
+		}
+
+		if target.Token == token.INTERFACE {
+			delete(p.Types, name)
+			p.Interfaces[name] = ob
+			for _, f := range p.Files {
+				if f.Types[name] == ob {
+					delete(f.Types, name)
+					f.Interfaces[name] = ob
+				}
+			}
+		}
+	}
+}
+
+// methodOwner returns the type whose methods the alias has, following only the aliases (e.g. S2 for type A = S2, even if type S2 S).
+// If ob is not an alias, nil is returned.
+func methodOwner(p *Package, ob *Object) *Object {
+	if !ob.Alias {
+		return nil
+	}
+	seen := map[*Object]bool{ob: true}
+	for ob.Alias {
+		next, ok := p.Types[ob.Underlying]
+		if !ok || seen[next] {
+			return nil
+		}
+		seen[next] = true
+		ob = next
+	}
+	return ob
+}
+
+// lookupTarget follows the chain of type definitions, and returns the original struct or interface type.
+func lookupTarget(p *Package, ob *Object) *Object {
+	seen := map[*Object]bool{ob: true}
+	for ob.Underlying != "" {
+		next, ok := p.Types[ob.Underlying]
+		if !ok {
+			next, ok = p.Interfaces[ob.Underlying]
+		}
+		if !ok || seen[next] {
+			return nil
+		}
+		seen[next] = true
+		ob = next
+	}
+	if ob.Token != token.STRUCT && ob.Token != token.INTERFACE {
+		return nil
+	}
+	return ob
+}
+
+// computeMethodSets sets the value method set and the pointer method set of each type.
+// Methods promoted from embedded in-package types are also included (ambiguous ones are dropped, as Go does).
+func computeMethodSets(p *Package) {
+	for _, ob := range p.Types {
+		ob.ValueMethodSet, ob.PointerMethodSet = methodSets(p, ob)
+	}
+}
+
+func methodSets(p *Package, ob *Object) (value []string, pointer []string) {
+	for _, s := range methodSelectors(p, ob, map[*Object]bool{}) {
+		if s.Ambiguous || s.Field {
+			continue
+		}
+		pointer = append(pointer, s.Name)
+		if s.Value {
+			value = append(value, s.Name)
+		}
+	}
+	return value, pointer
+}
+
+// methodSelector is the method selectable from the type, with the depth of the embedding (0 is the method of the type itself).
+type methodSelector struct {
+	Name      string
+	Depth     int
+	Value     bool // in the value method set
+	Ambiguous bool // promoted from the multiple embedded types at the same depth
+	Field     bool // the field (it is not a method, but it shadows the deeper methods)
+}
+
+// methodSelectors returns the methods of the type and the promoted methods (shallower selectors shadow deeper ones).
+// The fields are also returned, because a field and a method share the selector (e.g. T.Name).
+func methodSelectors(p *Package, ob *Object, seen map[*Object]bool) []*methodSelector {
+	if seen[ob] {
+		return nil
+	}
+	seen[ob] = true
+	defer delete(seen, ob) // the same type can be embedded via the different paths
+
+	var r []*methodSelector
+	index := map[string]*methodSelector{}
+	for _, name := range ob.MethodNames {
+		s := &methodSelector{Name: name, Value: !ob.Methods[name].PointerReceiver}
+		index[name] = s
+		r = append(r, s)
+	}
+	if ob.Token != token.STRUCT {
+		return r
+	}
+	for _, id := range ob.FieldNames {
+		name := selectorName(ob.Fields[id])
+		if _, ok := index[name]; ok || name == "" {
+			continue
+		}
+		s := &methodSelector{Name: name, Field: true}
+		index[name] = s
+		r = append(r, s)
+	}
+
+	for _, id := range ob.FieldNames {
+		field := ob.Fields[id]
+		if !field.Embedded {
+			continue
+		}
+		name := strings.TrimPrefix(field.Name, "*")
+		var promoted []*methodSelector
+		if embedded, ok := p.Interfaces[name]; ok {
+			methods, _ := interfaceMethods(p, embedded, map[*Object]bool{})
+			for _, m := range methods {
+				promoted = append(promoted, &methodSelector{Name: m.Name, Value: true})
+			}
+		} else if embedded, ok := p.Types[name]; ok {
+			promoted = methodSelectors(p, embedded, seen)
+			if strings.HasPrefix(field.Name, "*") {
+				for _, s := range promoted {
+					s.Value = true
+				}
+			}
+		}
+
+		for _, s := range promoted {
+			s.Depth++
+			prev, ok := index[s.Name]
+			if !ok {
+				index[s.Name] = s
+				r = append(r, s)
+			} else if prev.Depth == s.Depth {
+				prev.Ambiguous = true
+			} else if prev.Depth > s.Depth {
+				*prev = *s
+			}
+		}
+	}
+	return r
+}
+
+// selectorName returns the name selecting the field (e.g. T for the embedded field *pkg.T[int]).
+func selectorName(field *Field) string {
+	name := strings.TrimPrefix(field.Name, "*")
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// interfaceMethods returns the methods of the interface, including the methods of embedded in-package interfaces.
+// If some embedded interfaces cannot be resolved, ok is false.
+func interfaceMethods(p *Package, ob *Object, seen map[*Object]bool) (methods []*Field, ok bool) {
+	if seen[ob] {
+		return nil, true
+	}
+	seen[ob] = true
+
+	ok = true
+	for _, id := range ob.FieldNames {
+		field := ob.Fields[id]
+		if !field.Embedded {
+			methods = append(methods, field)
+			continue
+		}
+
+		embedded := field.Anonymous
+		if embedded == nil {
+			embedded = p.Interfaces[field.Name]
+		}
+		if embedded == nil {
+			ok = false
+			continue
+		}
+		sub, subOK := interfaceMethods(p, embedded, seen)
+		methods = append(methods, sub...)
+		ok = ok && subOK
+	}
+	return methods, ok
+}
+
+func contains(xs []string, x string) bool {
+	for _, v := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
 func ignoreExported(p *Package) {
 	names := make([]string, 0, len(p.Names))
 	for _, name := range p.Names {
@@ -75,12 +662,48 @@ func ignoreExported(p *Package) {
 		} else if _, ok := p.Interfaces[name]; ok {
 			delete(p.Interfaces, name)
 			continue
+		} else if _, ok := p.Constants[name]; ok {
+			delete(p.Constants, name)
+			continue
 		}
 	}
 	p.Names = names
 	for _, f := range p.Files {
 		ignoreExportedForFile(f)
 	}
+	p.Implements = ignoreExportedForRelation(p.Implements)
+	p.Implementations = ignoreExportedForRelation(p.Implementations)
+}
+
+func ignoreExportedForRelation(relation map[string][]string) map[string][]string {
+	if relation == nil {
+		return nil
+	}
+	r := make(map[string][]string, len(relation))
+	for k, vs := range relation {
+		if !isExportedTypeName(k) {
+			continue
+		}
+		names := make([]string, 0, len(vs))
+		for _, v := range vs {
+			if isExportedTypeName(v) {
+				names = append(names, v)
+			}
+		}
+		if len(names) > 0 {
+			r[k] = names
+		}
+	}
+	return r
+}
+
+// isExportedTypeName reports whether the type name (e.g. T, *T, pkg.T) is exported.
+func isExportedTypeName(name string) bool {
+	name = strings.TrimPrefix(name, "*")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return ast.IsExported(name)
 }
 
 func ignoreExportedForFile(f *File) {
@@ -107,6 +730,9 @@ func ignoreExportedForFile(f *File) {
 		} else if _, ok := f.Functions[name]; ok {
 			delete(f.Functions, name)
 			continue
+		} else if _, ok := f.Constants[name]; ok {
+			delete(f.Constants, name)
+			continue
 		}
 	}
 	f.Names = names
@@ -116,7 +742,7 @@ func ignoreExportedForObject(ob *Object) {
 	if len(ob.Fields) > 0 {
 		names := make([]string, 0, len(ob.FieldNames))
 		for _, name := range ob.FieldNames {
-			if ast.IsExported(name) {
+			if field, ok := ob.Fields[name]; ast.IsExported(name) || (ok && field.Embedded && isExportedTypeName(name)) { // embedded *T, pkg.T
 				names = append(names, name)
 				continue
 			}
@@ -135,4 +761,20 @@ func ignoreExportedForObject(ob *Object) {
 		}
 		ob.MethodNames = names
 	}
+	ob.ValueMethodSet = exportedNames(ob.ValueMethodSet)
+	ob.PointerMethodSet = exportedNames(ob.PointerMethodSet)
+	ob.MethodSet = exportedNames(ob.MethodSet)
+}
+
+func exportedNames(names []string) []string {
+	if len(names) == 0 {
+		return names
+	}
+	exported := make([]string, 0, len(names))
+	for _, name := range names {
+		if ast.IsExported(name) {
+			exported = append(exported, name)
+		}
+	}
+	return exported
 }
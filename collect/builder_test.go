@@ -0,0 +1,433 @@
+package collect
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sourceFile struct {
+	name string
+	src  string
+}
+
+// buildFiles collects the files with the builder, and returns the built package (nil, if AddFile fails).
+func buildFiles(t *testing.T, b *PackageBuilder, files ...sourceFile) (*Package, error) {
+	t.Helper()
+	fset := token.NewFileSet()
+	var trees []*ast.File
+	for _, f := range files {
+		tree, err := parser.ParseFile(fset, f.name, f.src, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("parse %s: %+v", f.name, err)
+		}
+		trees = append(trees, tree)
+	}
+	if b.Package == nil {
+		b.Package = NewPackage()
+	}
+	b.Fset = fset
+	c := &Collector{Fset: fset, Dot: ".", Sharp: "#", Constants: true}
+	if err := c.CollectFromFiles(b, trees); err != nil {
+		return nil, err
+	}
+	return b.Build(), nil
+}
+
+func diagnosticCodes(p *Package) []string {
+	var codes []string
+	for _, d := range p.Diagnostics {
+		codes = append(codes, d.Code)
+	}
+	return codes
+}
+
+func TestAddFileConflict(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy ConflictPolicy
+		files  []sourceFile
+
+		wantNames       []string
+		wantDiagnostics []string
+		check           func(t *testing.T, p *Package)
+	}{
+		{
+			name: "type and func in the different files",
+			files: []sourceFile{
+				{"a.go", "package p\n\ntype Foo int\n"},
+				{"b.go", "package p\n\nfunc Foo() {}\n"},
+			},
+			wantNames:       []string{"Foo"},
+			wantDiagnostics: []string{"duplicate-symbol"},
+			check: func(t *testing.T, p *Package) {
+				if _, ok := p.Types["Foo"]; !ok {
+					t.Errorf("type Foo (the first one) is not kept")
+				}
+				if _, ok := p.Functions["Foo"]; ok {
+					t.Errorf("func Foo is kept, but it cannot be a variant of the type")
+				}
+			},
+		},
+		{
+			name: "methods of the dropped type",
+			files: []sourceFile{
+				{"a.go", "package p\n\nfunc Hello() {}\n"},
+				{"b.go", "package p\n\ntype Hello int\n\nfunc (Hello) M() {}\n\nfunc (*Hello) N() {}\n"},
+			},
+			wantNames:       []string{"Hello"},
+			wantDiagnostics: []string{"duplicate-symbol"},
+			check: func(t *testing.T, p *Package) {
+				if len(p.Functions) != 1 {
+					t.Errorf("functions: want only Hello, but got %v", p.Functions)
+				}
+				msg := p.Diagnostics[0].Message
+				if !strings.Contains(msg, "Hello#M") || !strings.Contains(msg, "*Hello#N") {
+					t.Errorf("the dropped methods are not reported with the conflict: %q", msg)
+				}
+			},
+		},
+		{
+			name: "methods of the other kind in the later file",
+			files: []sourceFile{
+				{"a.go", "package p\n\nfunc Hello() {}\n"},
+				{"b.go", "package p\n\nfunc (Hello) M() {}\n\nfunc (*Hello) N() {}\n"},
+			},
+			wantNames:       []string{"Hello"},
+			wantDiagnostics: []string{"duplicate-symbol", "duplicate-symbol"},
+			check: func(t *testing.T, p *Package) {
+				if len(p.Functions) != 1 {
+					t.Errorf("functions: want only Hello, but got %v", p.Functions)
+				}
+			},
+		},
+		{
+			name: "methods of the variants",
+			files: []sourceFile{
+				{"file_linux.go", "package p\n\ntype File struct{}\n\nfunc (File) Fd() {}\n"},
+				{"file_windows.go", "package p\n\ntype File struct{}\n\nfunc (File) Fd() {}\n\nfunc (*File) Handle() {}\n\nfunc (File) Name() {}\n"},
+			},
+			wantNames: []string{"File"},
+			check: func(t *testing.T, p *Package) {
+				ob := p.Types["File"]
+				if want := []string{"Fd", "Handle", "Name"}; !reflect.DeepEqual(ob.MethodNames, want) {
+					t.Errorf("methodnames: want %v, but got %v", want, ob.MethodNames)
+				}
+				if len(p.Functions) != 0 {
+					t.Errorf("functions: want none, but got %v", p.Functions)
+				}
+			},
+		},
+		{
+			name: "const and interface in the same file",
+			files: []sourceFile{
+				{"a.go", "package p\n\nconst Foo = 1\n\ntype Foo interface{}\n"},
+			},
+			wantNames:       []string{"Foo"},
+			wantDiagnostics: []string{"duplicate-symbol"},
+		},
+		{
+			name: "methods of T and *T",
+			files: []sourceFile{
+				{"a.go", "package p\n\ntype Ob struct{}\n\n// M of Ob\nfunc (Ob) M() {}\n"},
+				{"b.go", "package p\n\n// M of *Ob\nfunc (*Ob) M() {}\n"},
+			},
+			wantNames:       []string{"Ob"},
+			wantDiagnostics: []string{"duplicate-symbol"},
+			check: func(t *testing.T, p *Package) {
+				ob := p.Types["Ob"]
+				if want := []string{"M"}; !reflect.DeepEqual(ob.MethodNames, want) {
+					t.Errorf("methodnames: want %v, but got %v", want, ob.MethodNames)
+				}
+				if got := ob.Methods["M"].Doc; got != "M of Ob\n" {
+					t.Errorf("the doc of the first method is overwritten: %q", got)
+				}
+				if got := len(ob.Methods["M"].Variants); got != 1 {
+					t.Errorf("variants: want 1, but got %d", got)
+				}
+				if want := []string{"M"}; !reflect.DeepEqual(ob.PointerMethodSet, want) {
+					t.Errorf("pointermethodset: want %v, but got %v", want, ob.PointerMethodSet)
+				}
+			},
+		},
+		{
+			name: "same kind, keep variants",
+			files: []sourceFile{
+				{"a.go", "package p\n\nfunc Hello() {}\n"},
+				{"b.go", "package p\n\nfunc Hello() {}\n"},
+			},
+			wantNames:       []string{"Hello"},
+			wantDiagnostics: []string{"duplicate-symbol"},
+			check: func(t *testing.T, p *Package) {
+				if got := len(p.Functions["Hello"].Variants); got != 1 {
+					t.Errorf("variants: want 1, but got %d", got)
+				}
+			},
+		},
+		{
+			name:   "same kind, keep first",
+			policy: ConflictKeepFirst,
+			files: []sourceFile{
+				{"a.go", "package p\n\nfunc Hello() {}\n"},
+				{"b.go", "package p\n\nfunc Hello() {}\n"},
+			},
+			wantNames:       []string{"Hello"},
+			wantDiagnostics: []string{"duplicate-symbol"},
+			check: func(t *testing.T, p *Package) {
+				if got := len(p.Functions["Hello"].Variants); got != 0 {
+					t.Errorf("variants: want 0, but got %d", got)
+				}
+			},
+		},
+		{
+			name: "different platforms",
+			files: []sourceFile{
+				{"open_linux.go", "package p\n\nfunc Open() {}\n"},
+				{"open_windows.go", "package p\n\nfunc Open() {}\n"},
+			},
+			wantNames: []string{"Open"},
+			check: func(t *testing.T, p *Package) {
+				if got := len(p.Functions["Open"].Variants); got != 1 {
+					t.Errorf("variants: want 1, but got %d", got)
+				}
+			},
+		},
+		{
+			name: "init functions",
+			files: []sourceFile{
+				{"a.go", "package p\n\nfunc init() {}\n"},
+				{"b.go", "package p\n\nfunc init() {}\n"},
+			},
+			wantNames: []string{"init"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			b := &PackageBuilder{EnableMergeMethod: true, ConflictPolicy: c.policy}
+			p, err := buildFiles(t, b, c.files...)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if !reflect.DeepEqual(p.Names, c.wantNames) {
+				t.Errorf("names: want %v, but got %v", c.wantNames, p.Names)
+			}
+			if got := diagnosticCodes(p); !reflect.DeepEqual(got, c.wantDiagnostics) {
+				t.Errorf("diagnostics: want %v, but got %v", c.wantDiagnostics, got)
+			}
+			if c.check != nil {
+				c.check(t, p)
+			}
+		})
+	}
+}
+
+func TestAddFileConflictError(t *testing.T) {
+	cases := []struct {
+		name  string
+		files []sourceFile
+	}{
+		{
+			name: "same kind",
+			files: []sourceFile{
+				{"a.go", "package p\n\ntype Foo int\n"},
+				{"b.go", "package p\n\ntype Bar int\n\ntype Foo int\n"},
+			},
+		},
+		{
+			name: "different kinds",
+			files: []sourceFile{
+				{"a.go", "package p\n\ntype Foo int\n"},
+				{"b.go", "package p\n\ntype Bar int\n\nfunc Foo() {}\n"},
+			},
+		},
+		{
+			name: "methods of T and *T",
+			files: []sourceFile{
+				{"a.go", "package p\n\ntype Foo int\n\nfunc (Foo) M() {}\n"},
+				{"b.go", "package p\n\ntype Bar int\n\nfunc (*Foo) M() {}\n"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var reported []*Diagnostic
+			b := &PackageBuilder{
+				Package:        NewPackage(),
+				ConflictPolicy: ConflictError,
+				Report:         func(d *Diagnostic) { reported = append(reported, d) },
+			}
+			_, err := buildFiles(t, b, c.files...)
+
+			var d *Diagnostic
+			if !errors.As(err, &d) {
+				t.Fatalf("want the diagnostic as the error, but got %+v", err)
+			}
+			if d.Code != "duplicate-symbol" || d.Severity != SeverityError {
+				t.Errorf("unexpected diagnostic: %+v", d)
+			}
+			if len(reported) != 1 || reported[0] != d {
+				t.Errorf("the diagnostic is not reported: %v", reported)
+			}
+
+			// the package is left as it was before b.go
+			p := b.Package
+			if want := []string{"a.go"}; !reflect.DeepEqual(p.FileNames, want) {
+				t.Errorf("filenames: want %v, but got %v", want, p.FileNames)
+			}
+			if _, ok := p.Files["b.go"]; ok {
+				t.Errorf("b.go is added")
+			}
+			if _, ok := p.Types["Bar"]; ok {
+				t.Errorf("Bar (before the conflict in b.go) is added")
+			}
+			if len(p.Diagnostics) != 0 {
+				t.Errorf("diagnostics: want none, but got %v", p.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestAddFileConflictReport(t *testing.T) {
+	files := []sourceFile{
+		{"a.go", "package p\n\ntype Foo int\n"},
+		{"b.go", "package p\n\nfunc Foo() {}\n"},
+	}
+
+	t.Run("report", func(t *testing.T) {
+		var reported []string
+		b := &PackageBuilder{Report: func(d *Diagnostic) { reported = append(reported, d.Code) }}
+		p, err := buildFiles(t, b, files...)
+		if err != nil {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+		if want := []string{"duplicate-symbol"}; !reflect.DeepEqual(reported, want) {
+			t.Errorf("reported: want %v, but got %v", want, reported)
+		}
+		if want := []string{"duplicate-symbol"}; !reflect.DeepEqual(diagnosticCodes(p), want) {
+			t.Errorf("diagnostics: want %v, but got %v", want, diagnosticCodes(p))
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		b := &PackageBuilder{Strict: true}
+		_, err := buildFiles(t, b, files...)
+		var d *Diagnostic
+		if !errors.As(err, &d) || d.Code != "duplicate-symbol" {
+			t.Fatalf("want the duplicate-symbol error, but got %+v", err)
+		}
+	})
+}
+
+func TestMethodSets(t *testing.T) {
+	cases := []struct {
+		name        string
+		src         string
+		typ         string
+		wantValue   []string
+		wantPointer []string
+	}{
+		{
+			name:        "own methods",
+			src:         "type T struct{}\n\nfunc (T) V() {}\n\nfunc (*T) P() {}\n",
+			typ:         "T",
+			wantValue:   []string{"V"},
+			wantPointer: []string{"V", "P"},
+		},
+		{
+			name:        "promoted from *E",
+			src:         "type E struct{}\n\nfunc (*E) P() {}\n\ntype T struct {\n\t*E\n}\n",
+			typ:         "T",
+			wantValue:   []string{"P"},
+			wantPointer: []string{"P"},
+		},
+		{
+			name: "ambiguous at the same depth",
+			src:  "type A struct{}\n\nfunc (A) M() {}\n\ntype B struct{}\n\nfunc (B) M() {}\n\ntype T struct {\n\tA\n\tB\n}\n",
+			typ:  "T",
+		},
+		{
+			name:        "shallower wins over the ambiguous one",
+			src:         "type A struct{}\n\nfunc (A) M() {}\n\ntype B struct{}\n\nfunc (B) M() {}\n\ntype AB struct {\n\tA\n\tB\n}\n\ntype C struct{}\n\nfunc (C) M() {}\n\ntype T struct {\n\tAB\n\tC\n}\n",
+			typ:         "T",
+			wantValue:   []string{"M"},
+			wantPointer: []string{"M"},
+		},
+		{
+			name: "field shadows the promoted method",
+			src:  "type Ob struct{}\n\nfunc (Ob) Name() string { return \"\" }\n\ntype T struct {\n\tName string\n\tOb\n}\n",
+			typ:  "T",
+		},
+		{
+			name: "field and method at the same depth",
+			src:  "type W struct {\n\tM int\n}\n\ntype E struct{}\n\nfunc (E) M() {}\n\ntype T struct {\n\tW\n\tE\n}\n",
+			typ:  "T",
+		},
+		{
+			name:        "method shadows the deeper field",
+			src:         "type W struct {\n\tM int\n}\n\ntype T struct {\n\tW\n}\n\nfunc (T) M() {}\n",
+			typ:         "T",
+			wantValue:   []string{"M"},
+			wantPointer: []string{"M"},
+		},
+		{
+			name:        "embedded field shadows the method of the same name",
+			src:         "type Ob struct{}\n\nfunc (Ob) Ob() {}\n\nfunc (Ob) V() {}\n\ntype W struct {\n\tOb\n}\n\ntype T struct {\n\tW\n}\n",
+			typ:         "T",
+			wantValue:   []string{"V"},
+			wantPointer: []string{"V"},
+		},
+		{
+			name:        "shallower wins over the earlier deeper one",
+			src:         "type A struct{}\n\nfunc (*A) M() {}\n\ntype W struct {\n\tA\n}\n\ntype B struct{}\n\nfunc (B) M() {}\n\ntype T struct {\n\tW\n\tB\n}\n",
+			typ:         "T",
+			wantValue:   []string{"M"},
+			wantPointer: []string{"M"},
+		},
+		{
+			name: "same type via the different paths",
+			src:  "type E struct{}\n\nfunc (E) M() {}\n\ntype A struct {\n\tE\n}\n\ntype B struct {\n\tE\n}\n\ntype T struct {\n\tA\n\tB\n}\n",
+			typ:  "T",
+		},
+		{
+			name: "alias of the defined type",
+			src:  "type S struct{}\n\nfunc (S) M() {}\n\ntype S2 S\n\ntype A = S2\n",
+			typ:  "A",
+		},
+		{
+			name:        "alias of the alias",
+			src:         "type S struct{}\n\nfunc (S) M() {}\n\ntype S2 = S\n\ntype A = S2\n",
+			typ:         "A",
+			wantValue:   []string{"M"},
+			wantPointer: []string{"M"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			b := &PackageBuilder{EnableMergeMethod: true, ResolveTypeDefs: true}
+			p, err := buildFiles(t, b, sourceFile{"a.go", "package p\n\n" + c.src})
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			ob, ok := p.Types[c.typ]
+			if !ok {
+				t.Fatalf("type %s is not found", c.typ)
+			}
+			if !reflect.DeepEqual(ob.ValueMethodSet, c.wantValue) {
+				t.Errorf("valuemethodset: want %v, but got %v", c.wantValue, ob.ValueMethodSet)
+			}
+			if !reflect.DeepEqual(ob.PointerMethodSet, c.wantPointer) {
+				t.Errorf("pointermethodset: want %v, but got %v", c.wantPointer, ob.PointerMethodSet)
+			}
+		})
+	}
+}
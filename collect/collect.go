@@ -3,38 +3,86 @@ package collect
 import (
 	"fmt"
 	"go/ast"
+	"go/scanner"
 	"go/token"
-	"log"
+	"go/types"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 type Collector struct {
 	Fset  *token.FileSet
 	Dot   string
 	Sharp string
+
+	Strict bool              // if true, the diagnostics (warning or error) are returned as errors
+	Report func(*Diagnostic) // if set, called for each diagnostic (the diagnostics are also stored in the file)
+
+	ParseErrors scanner.ErrorList // syntax errors of the partial ASTs returned by go/parser
+	Constants   bool              // if true, the constants are collected
 }
 
-func (c *Collector) CollectFromPackage(p *Package, t *ast.Package) error {
-	b := &PackageBuilder{Package: p}
+// report records the diagnostic in the file. In strict mode, it is returned as an error.
+func (c *Collector) report(f *File, pos token.Pos, severity Severity, code string, format string, args ...interface{}) error {
+	d := &Diagnostic{
+		Pos:      pos,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if c.Fset != nil && pos.IsValid() {
+		d.Position = c.Fset.Position(pos).String()
+	}
+	f.Diagnostics = append(f.Diagnostics, d)
+	if c.Report != nil {
+		c.Report(d)
+	}
+	if c.Strict && severity != SeverityInfo {
+		return d
+	}
+	return nil
+}
 
+func (c *Collector) CollectFromPackage(b *PackageBuilder, t *ast.Package) error {
 	filenames := make([]string, 0, len(t.Files))
 	for filename := range t.Files {
 		filenames = append(filenames, filename)
 	}
 	sort.Strings(filenames)
 
+	files := make([]*ast.File, 0, len(filenames))
 	for _, filename := range filenames {
-		ft := t.Files[filename]
+		files = append(files, t.Files[filename])
+	}
+	return c.CollectFromFiles(b, files)
+}
+
+// CollectFromFiles collects the files of a package, in the given order.
+func (c *Collector) CollectFromFiles(b *PackageBuilder, files []*ast.File) error {
+	for _, ft := range files {
+		filename := c.Fset.File(ft.Pos()).Name()
 		f := NewFile()
 		if err := c.CollectFromFile(f, ft); err != nil {
 			return fmt.Errorf("collect file: %s: %w", filename, err)
 		}
-		b.AddFile(f, filename)
+		if err := b.AddFile(f, filename); err != nil {
+			return fmt.Errorf("add file: %s: %w", filename, err)
+		}
 	}
 	return nil
 }
 
 func (c *Collector) CollectFromFile(f *File, t *ast.File) error {
+	if t.Name != nil {
+		f.Package = t.Name.Name
+	}
+	if tf := c.Fset.File(t.Pos()); tf != nil {
+		collectBuildConstraint(f, tf.Name(), t)
+		if err := c.collectParseErrors(f, tf); err != nil {
+			return err
+		}
+	}
 	for _, decl := range t.Decls {
 		switch decl := decl.(type) {
 		case *ast.BadDecl:
@@ -47,22 +95,74 @@ func (c *Collector) CollectFromFile(f *File, t *ast.File) error {
 				return err
 			}
 		default:
-			log.Printf("unexpected decl: %T?", decl)
+			if err := c.report(f, decl.Pos(), SeverityWarning, "unexpected-decl", "unexpected decl: %T", decl); err != nil {
+				return err
+			}
 			continue
 		}
 	}
 	return nil
 }
 
+// collectParseErrors marks the file as incomplete, and reports the syntax errors in the file as diagnostics.
+func (c *Collector) collectParseErrors(f *File, tf *token.File) error {
+	for _, e := range c.ParseErrors {
+		if e.Pos.Filename != tf.Name() {
+			continue
+		}
+		pos := token.NoPos
+		if e.Pos.Offset <= tf.Size() {
+			pos = tf.Pos(e.Pos.Offset)
+		}
+		f.Incomplete = true
+		f.errorPositions = append(f.errorPositions, pos)
+		if err := c.report(f, pos, SeverityError, "syntax-error", "%s", e.Msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Collector) CollectFromFuncDecl(f *File, t *ast.File, decl *ast.FuncDecl) error {
 	recv := ""
+	recvName := ""
+	pointerReceiver := false
+	var recvTypeParams []string
 	if decl.Recv != nil && decl.Recv.List != nil {
-		if v, ok := typeString(decl.Recv.List[0].Type); ok {
+		field := decl.Recv.List[0]
+		if len(field.Names) > 0 {
+			recvName = field.Names[0].Name
+		}
+
+		typ := field.Type
+		if t, ok := typ.(*ast.StarExpr); ok {
+			pointerReceiver = true
+			typ = t.X
+		}
+		switch t := typ.(type) {
+		case *ast.IndexExpr:
+			// func (x T[P]) ...
+			typ = t.X
+			recvTypeParams = append(recvTypeParams, types.ExprString(t.Index))
+		case *ast.IndexListExpr:
+			// func (x T[P, Q]) ...
+			typ = t.X
+			for _, index := range t.Indices {
+				recvTypeParams = append(recvTypeParams, types.ExprString(index))
+			}
+		}
+		if v, ok := typeString(typ); ok {
 			recv = v
+			if pointerReceiver {
+				recv = "*" + v
+			}
 		}
 	}
 
 	name := decl.Name.Name
+	if name == "_" {
+		return nil
+	}
 	id := name
 	if recv != "" {
 		id = recv + c.Sharp + name
@@ -126,6 +226,7 @@ func (c *Collector) CollectFromFuncDecl(f *File, t *ast.File, decl *ast.FuncDecl
 		field := &Field{
 			Name:    name,
 			Pos:     x.Pos(),
+			Type:    types.ExprString(x.Type),
 			Comment: doc,
 		}
 		params[id] = field
@@ -136,6 +237,7 @@ func (c *Collector) CollectFromFuncDecl(f *File, t *ast.File, decl *ast.FuncDecl
 				paramNames = append(paramNames, name)
 				params[name] = &Field{
 					Name: name,
+					Type: field.Type,
 				}
 			}
 		}
@@ -207,6 +309,7 @@ func (c *Collector) CollectFromFuncDecl(f *File, t *ast.File, decl *ast.FuncDecl
 			field := &Field{
 				Name:    name,
 				Pos:     x.Pos(),
+				Type:    types.ExprString(x.Type),
 				Comment: doc,
 			}
 			returns[id] = field
@@ -215,7 +318,7 @@ func (c *Collector) CollectFromFuncDecl(f *File, t *ast.File, decl *ast.FuncDecl
 				for _, id := range x.Names[1:] {
 					name := id.Name
 					returnNames = append(returnNames, name)
-					returns[name] = &Field{Name: name}
+					returns[name] = &Field{Name: name, Type: field.Type}
 				}
 			}
 		}
@@ -228,40 +331,91 @@ func (c *Collector) CollectFromFuncDecl(f *File, t *ast.File, decl *ast.FuncDecl
 	}
 
 	f.Functions[id] = &Func{
-		Name:        name,
-		Pos:         decl.Pos(),
-		Recv:        recv,
-		Doc:         decl.Doc.Text(),
-		Params:      params,
-		ParamNames:  paramNames,
-		Returns:     returns,
-		ReturnNames: returnNames,
+		Name:            name,
+		Pos:             decl.Pos(),
+		Incomplete:      f.hasParseError(decl),
+		Recv:            recv,
+		RecvName:        recvName,
+		PointerReceiver: pointerReceiver,
+		RecvTypeParams:  recvTypeParams,
+		Doc:             decl.Doc.Text(),
+		Params:          params,
+		ParamNames:      paramNames,
+		Returns:         returns,
+		ReturnNames:     returnNames,
 	}
 	return nil
 }
 
 func (c *Collector) CollectFromGenDecl(f *File, decl *ast.GenDecl) error {
-	for _, spec := range decl.Specs {
+	var typ ast.Expr      // for implicit repetition in const declarations
+	var values []ast.Expr // for implicit repetition in const declarations
+	for iota, spec := range decl.Specs {
 		switch spec := spec.(type) {
-		case *ast.ImportSpec, *ast.ValueSpec:
+		case *ast.ImportSpec:
+		case *ast.ValueSpec:
+			if decl.Tok != token.CONST {
+				continue
+			}
+			if spec.Type != nil || len(spec.Values) > 0 {
+				typ, values = spec.Type, spec.Values
+			}
+			if !c.Constants {
+				continue
+			}
+			if err := c.CollectFromConstSpec(f, decl, spec, typ, values, iota); err != nil {
+				return err
+			}
 		case *ast.TypeSpec:
 			if err := c.CollectFromTypeSpec(f, decl, spec); err != nil {
 				return err
 			}
 		default:
-			log.Printf("unexpected decl: %T, spec: %T?", decl, spec)
+			if err := c.report(f, spec.Pos(), SeverityWarning, "unexpected-spec", "unexpected spec: %T, decl: %T", spec, decl); err != nil {
+				return err
+			}
 			continue
 		}
 	}
 	return nil
 }
 
+func (c *Collector) CollectFromConstSpec(f *File, decl *ast.GenDecl, spec *ast.ValueSpec, typ ast.Expr, values []ast.Expr, iota int) error {
+	for i, ident := range spec.Names {
+		name := ident.Name
+		if name == "_" {
+			continue
+		}
+		f.Names = append(f.Names, name)
+		s := &Const{
+			Name:       name,
+			Pos:        ident.Pos(),
+			Incomplete: f.hasParseError(spec),
+			Iota:       iota,
+			Doc:        spec.Doc.Text(),
+			Comment:    spec.Comment.Text(),
+		}
+		if s.Doc == "" && decl.Doc != nil && !decl.Lparen.IsValid() {
+			s.Doc = decl.Doc.Text()
+		}
+		if typ != nil {
+			s.Type = types.ExprString(typ)
+		}
+		if i < len(values) {
+			s.Value = types.ExprString(values[i])
+		}
+		f.Constants[name] = s
+	}
+	return nil
+}
+
 func (c *Collector) CollectFromTypeSpec(f *File, decl *ast.GenDecl, spec *ast.TypeSpec) error {
 	name := spec.Name.Name
 	f.Names = append(f.Names, name)
 	s := &Object{
 		Name:       name,
 		Pos:        decl.Pos(),
+		Incomplete: f.hasParseError(spec),
 		Doc:        spec.Doc.Text(),
 		Comment:    spec.Comment.Text(),
 		FieldNames: []string{},
@@ -271,11 +425,13 @@ func (c *Collector) CollectFromTypeSpec(f *File, decl *ast.GenDecl, spec *ast.Ty
 	if s.Doc == "" && decl.Doc != nil {
 		s.Doc = decl.Doc.Text()
 	}
+	s.Alias = spec.Assign.IsValid()
 
 	switch typ := spec.Type.(type) {
 	case *ast.Ident:
 		// type <S> <S>
 		// type <S> = <S>
+		s.Underlying = typ.Name
 		f.Types[name] = s
 	case *ast.StructType:
 		// type <S> struct { ... }
@@ -289,10 +445,19 @@ func (c *Collector) CollectFromTypeSpec(f *File, decl *ast.GenDecl, spec *ast.Ty
 		if err := c.CollectFromInterfaceType(f, s, decl, spec, typ); err != nil {
 			return err
 		}
-	case *ast.FuncType:
+	case *ast.FuncType, *ast.SelectorExpr, *ast.StarExpr, *ast.ParenExpr,
+		*ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.IndexExpr, *ast.IndexListExpr:
+		// type <S> func(...) ...
+		// type <S> pkg.<S>
+		// type <S> []<S>
+		s.Underlying = types.ExprString(typ)
 		f.Types[name] = s
 	default:
-		log.Printf("unexpected decl: %T, spec: %T, type: %T?", decl, spec, typ)
+		s.Underlying = types.ExprString(typ)
+		f.Types[name] = s
+		if err := c.report(f, spec.Type.Pos(), SeverityWarning, "unsupported-type", "unsupported type: %T, type=%s", typ, name); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -310,11 +475,58 @@ func typeString(typ ast.Expr) (string, bool) {
 	case *ast.StarExpr:
 		name, ok := typeString(t.X)
 		return "*" + name, ok
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		// generic type (e.g. List[int])
+		return types.ExprString(t), true
 	default:
 		return "", false
 	}
 }
 
+// funcTypeString returns the signature without parameter names (e.g. func(int, string) (string, error)).
+func funcTypeString(typ *ast.FuncType) string {
+	var params, results []string
+	for _, x := range typ.Params.List {
+		for i, n := 0, max1(len(x.Names)); i < n; i++ {
+			params = append(params, types.ExprString(x.Type))
+		}
+	}
+	if typ.Results != nil {
+		for _, x := range typ.Results.List {
+			for i, n := 0, max1(len(x.Names)); i < n; i++ {
+				results = append(results, types.ExprString(x.Type))
+			}
+		}
+	}
+	return FormatSignature(params, results)
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// FormatSignature formats the types of params and results as a function type.
+func FormatSignature(params []string, results []string) string {
+	var b strings.Builder
+	b.WriteString("func(")
+	b.WriteString(strings.Join(params, ", "))
+	b.WriteString(")")
+	switch len(results) {
+	case 0:
+	case 1:
+		b.WriteString(" ")
+		b.WriteString(results[0])
+	default:
+		b.WriteString(" (")
+		b.WriteString(strings.Join(results, ", "))
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
 func (c *Collector) CollectFromStructType(f *File, s *Object, decl *ast.GenDecl, spec *ast.TypeSpec, typ *ast.StructType) error {
 	s.Token = token.STRUCT
 	for i, field := range typ.Fields.List {
@@ -327,8 +539,10 @@ func (c *Collector) CollectFromStructType(f *File, s *Object, decl *ast.GenDecl,
 			if typename, ok := typeString(field.Type); ok {
 				name = typename
 			} else {
-				name = fmt.Sprintf("??%T", field.Type) // TODO: NG
-				log.Printf("unexpected embedded field type: %T, spec: %T, struct: %T, field:%v", decl, spec, typ, field.Type)
+				name = types.ExprString(field.Type)
+				if err := c.report(f, field.Pos(), SeverityWarning, "unexpected-embedded-type", "unexpected embedded field type: %T, struct=%s, field=%s", field.Type, s.Name, name); err != nil {
+					return err
+				}
 			}
 		}
 		id := name
@@ -340,10 +554,16 @@ func (c *Collector) CollectFromStructType(f *File, s *Object, decl *ast.GenDecl,
 		fieldof := &Field{
 			Name:     name,
 			Pos:      field.Pos(),
+			Type:     types.ExprString(field.Type),
 			Doc:      field.Doc.Text(),
 			Comment:  field.Comment.Text(),
 			Embedded: anonymous,
 		}
+		if field.Tag != nil {
+			if tag, err := strconv.Unquote(field.Tag.Value); err == nil {
+				fieldof.Tag = tag
+			}
+		}
 		s.Fields[id] = fieldof
 
 		switch typ := field.Type.(type) {
@@ -386,7 +606,9 @@ func (c *Collector) CollectFromStructType(f *File, s *Object, decl *ast.GenDecl,
 			*ast.StarExpr, *ast.UnaryExpr, *ast.BinaryExpr, *ast.KeyValueExpr,
 			*ast.ArrayType, *ast.MapType, *ast.ChanType:
 		default:
-			log.Printf("unexpected decl: %T, spec: %T, type: %T?, field=%s", decl, spec, typ, name)
+			if err := c.report(f, field.Pos(), SeverityWarning, "unexpected-field-type", "unexpected field type: %T, field=%s", typ, name); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -404,8 +626,15 @@ func (c *Collector) CollectFromInterfaceType(f *File, s *Object, decl *ast.GenDe
 			if typename, ok := typeString(field.Type); ok {
 				name = typename
 			} else {
-				name = fmt.Sprintf("??%T", field.Type) // TODO: NG
-				log.Printf("unexpected embedded field type: %T, spec: %T, struct: %T, field:%v", decl, spec, typ, field.Type)
+				name = types.ExprString(field.Type)
+				switch field.Type.(type) {
+				case *ast.UnaryExpr, *ast.BinaryExpr, *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.ParenExpr:
+					// type constraint (e.g. ~int | ~string)
+				default:
+					if err := c.report(f, field.Pos(), SeverityWarning, "unexpected-embedded-type", "unexpected embedded field type: %T, interface=%s, field=%s", field.Type, s.Name, name); err != nil {
+						return err
+					}
+				}
 			}
 		}
 		id := name
@@ -417,10 +646,17 @@ func (c *Collector) CollectFromInterfaceType(f *File, s *Object, decl *ast.GenDe
 		fieldof := &Field{
 			Name:     name,
 			Pos:      field.Pos(),
+			Type:     types.ExprString(field.Type),
 			Doc:      field.Doc.Text(),
 			Comment:  field.Comment.Text(),
 			Embedded: anonymous,
 		}
+		if typ, ok := field.Type.(*ast.FuncType); ok {
+			fieldof.Type = funcTypeString(typ) // method signature
+			if sig := types.ExprString(typ); sig != fieldof.Type {
+				fieldof.Signature = sig // e.g. func(id int64, force bool) error
+			}
+		}
 		s.Fields[id] = fieldof
 
 		switch typ := field.Type.(type) {
@@ -447,7 +683,9 @@ func (c *Collector) CollectFromInterfaceType(f *File, s *Object, decl *ast.GenDe
 			*ast.StarExpr, *ast.UnaryExpr, *ast.BinaryExpr, *ast.KeyValueExpr,
 			*ast.ArrayType, *ast.MapType, *ast.ChanType:
 		default:
-			log.Printf("unexpected decl: %T, spec: %T, type: %T?, field=%s", decl, spec, typ, name)
+			if err := c.report(f, field.Pos(), SeverityWarning, "unexpected-field-type", "unexpected field type: %T, field=%s", typ, name); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
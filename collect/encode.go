@@ -0,0 +1,114 @@
+package collect
+
+import (
+	"encoding/gob"
+	"go/token"
+	"io"
+)
+
+// EncodeFile encodes the collected file with encoding/gob (e.g. for caching).
+// The positions are stored as offsets in the token.File, so the file can be decoded for another FileSet.
+func EncodeFile(w io.Writer, f *File, tf *token.File) error {
+	base := token.Pos(tf.Base())
+	f.mapPos(func(pos token.Pos) token.Pos { return pos - base + 1 })
+	defer f.mapPos(func(pos token.Pos) token.Pos { return pos + base - 1 })
+
+	f.walk(func(parent, ob *Object) { ob.Parent = nil }, nil) // gob cannot encode cyclic references
+	defer f.walk(func(parent, ob *Object) { ob.Parent = parent }, nil)
+	return gob.NewEncoder(w).Encode(f)
+}
+
+// DecodeFile decodes the file encoded by EncodeFile. The positions are relocated into tf.
+func DecodeFile(r io.Reader, tf *token.File) (*File, error) {
+	f := NewFile()
+	if err := gob.NewDecoder(r).Decode(f); err != nil {
+		return nil, err
+	}
+
+	base := token.Pos(tf.Base())
+	f.mapPos(func(pos token.Pos) token.Pos { return pos + base - 1 })
+	f.walk(func(parent, ob *Object) {
+		ob.Parent = parent
+		if ob.Fields == nil { // gob omits empty maps and slices
+			ob.Fields = map[string]*Field{}
+		}
+		if ob.FieldNames == nil {
+			ob.FieldNames = []string{}
+		}
+		if ob.Methods == nil {
+			ob.Methods = map[string]*Func{}
+		}
+		if ob.MethodNames == nil {
+			ob.MethodNames = []string{}
+		}
+	}, func(fn *Func) {
+		if fn.Params == nil {
+			fn.Params = map[string]*Field{}
+		}
+		if fn.ParamNames == nil {
+			fn.ParamNames = []string{}
+		}
+		if fn.Returns == nil {
+			fn.Returns = map[string]*Field{}
+		}
+		if fn.ReturnNames == nil {
+			fn.ReturnNames = []string{}
+		}
+	})
+	return f, nil
+}
+
+// walk visits the objects (with the parent, for anonymous objects) and the functions in the file.
+func (f *File) walk(visitObject func(parent, ob *Object), visitFunc func(fn *Func)) {
+	var walkObject func(parent, ob *Object)
+	walkObject = func(parent, ob *Object) {
+		if visitObject != nil {
+			visitObject(parent, ob)
+		}
+		for _, field := range ob.Fields {
+			if field.Anonymous != nil {
+				walkObject(ob, field.Anonymous)
+			}
+		}
+	}
+	for _, ob := range f.Interfaces {
+		walkObject(nil, ob)
+	}
+	for _, ob := range f.Types {
+		walkObject(nil, ob)
+	}
+	if visitFunc != nil {
+		for _, fn := range f.Functions {
+			visitFunc(fn)
+		}
+	}
+}
+
+// mapPos replaces the valid positions in the file.
+func (f *File) mapPos(fn func(token.Pos) token.Pos) {
+	conv := func(pos *token.Pos) {
+		if pos.IsValid() {
+			*pos = fn(*pos)
+		}
+	}
+	f.walk(func(_, ob *Object) {
+		conv(&ob.Pos)
+		for _, field := range ob.Fields {
+			conv(&field.Pos)
+		}
+	}, func(fn *Func) {
+		conv(&fn.Pos)
+		for _, x := range fn.Params {
+			conv(&x.Pos)
+		}
+		for _, x := range fn.Returns {
+			conv(&x.Pos)
+		}
+	})
+	for _, c := range f.Constants {
+		conv(&c.Pos)
+	}
+	for _, d := range f.Diagnostics {
+		conv(&d.Pos)
+	}
+}
@@ -0,0 +1,92 @@
+package collect
+
+import "go/token"
+
+// detectImplementations relates the types and the interfaces in the package, by comparing the names and signatures of methods.
+// Interfaces embedding the interfaces of other packages are skipped, because they cannot be resolved syntactically.
+func detectImplementations(p *Package) {
+	p.Implements = map[string][]string{}
+	p.Implementations = map[string][]string{}
+
+	seen := map[string]bool{}
+	for _, iname := range p.Names {
+		iface, ok := p.Interfaces[iname]
+		if !ok || seen[iname] {
+			continue
+		}
+		seen[iname] = true
+
+		methods, ok := interfaceMethods(p, iface, map[*Object]bool{})
+		if !ok || len(methods) == 0 {
+			continue
+		}
+
+		for _, tname := range p.Names {
+			ob, ok := p.Types[tname]
+			if !ok || ob.Token == token.INTERFACE || len(ob.PointerMethodSet) == 0 {
+				continue
+			}
+
+			impl := ""
+			if satisfies(p, ob, ob.ValueMethodSet, methods) {
+				impl = tname
+			} else if satisfies(p, ob, ob.PointerMethodSet, methods) {
+				impl = "*" + tname
+			} else {
+				continue
+			}
+			if contains(p.Implementations[iname], impl) {
+				continue
+			}
+			p.Implementations[iname] = append(p.Implementations[iname], impl)
+			p.Implements[impl] = append(p.Implements[impl], iname)
+		}
+	}
+}
+
+func satisfies(p *Package, ob *Object, methodSet []string, methods []*Field) bool {
+	for _, m := range methods {
+		if !contains(methodSet, m.Name) {
+			return false
+		}
+		if sig, ok := methodSignature(p, ob, m.Name, map[*Object]bool{}); !ok || sig != m.Type {
+			return false
+		}
+	}
+	return true
+}
+
+// methodSignature returns the signature of the method (or promoted method) of the type.
+func methodSignature(p *Package, ob *Object, name string, seen map[*Object]bool) (string, bool) {
+	if seen[ob] {
+		return "", false
+	}
+	seen[ob] = true
+
+	if fn, ok := ob.Methods[name]; ok {
+		return fn.Signature(), true
+	}
+	for _, id := range ob.FieldNames {
+		field := ob.Fields[id]
+		if !field.Embedded {
+			continue
+		}
+		typename := field.Name
+		if len(typename) > 0 && typename[0] == '*' {
+			typename = typename[1:]
+		}
+		if embedded, ok := p.Interfaces[typename]; ok {
+			methods, _ := interfaceMethods(p, embedded, map[*Object]bool{})
+			for _, m := range methods {
+				if m.Name == name {
+					return m.Type, true
+				}
+			}
+		} else if embedded, ok := p.Types[typename]; ok {
+			if sig, ok := methodSignature(p, embedded, name, seen); ok {
+				return sig, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,201 @@
+package collect
+
+import (
+	"go/ast"
+	"go/build/constraint"
+	"path/filepath"
+	"strings"
+)
+
+// Platform is the target platform, used for selecting files by build constraints.
+type Platform struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string // additional build tags (e.g. cgo, integration)
+}
+
+// Match reports whether the file is built on the platform.
+func (p *Platform) Match(f *File) bool {
+	if f.GOOS != "" && !p.matchTag(f.GOOS) {
+		return false
+	}
+	if f.GOARCH != "" && !p.matchTag(f.GOARCH) {
+		return false
+	}
+	if f.Constraint != "" {
+		expr, err := constraint.Parse("//go:build " + f.Constraint)
+		if err != nil {
+			return true
+		}
+		return expr.Eval(p.matchTag)
+	}
+	return true
+}
+
+func (p *Platform) matchTag(tag string) bool {
+	switch {
+	case tag == p.GOOS || tag == p.GOARCH:
+		return true
+	case tag == "unix":
+		return unixOS[p.GOOS]
+	case tag == "linux":
+		return p.GOOS == "android"
+	case tag == "solaris":
+		return p.GOOS == "illumos"
+	case tag == "darwin":
+		return p.GOOS == "ios"
+	case tag == "gc":
+		return true
+	case strings.HasPrefix(tag, "go1."):
+		return true // release tags
+	}
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildCondition returns the condition for building the file (e.g. linux && cgo), or empty if the file is always built.
+func (f *File) BuildCondition() string {
+	var conds []string
+	if f.GOOS != "" {
+		conds = append(conds, f.GOOS)
+	}
+	if f.GOARCH != "" {
+		conds = append(conds, f.GOARCH)
+	}
+	if f.Constraint != "" {
+		if len(conds) > 0 && strings.Contains(f.Constraint, "||") {
+			conds = append(conds, "("+f.Constraint+")")
+		} else {
+			conds = append(conds, f.Constraint)
+		}
+	}
+	return strings.Join(conds, " && ")
+}
+
+// collectBuildConstraint sets the //go:build expression and the GOOS/GOARCH of the filename suffix.
+func collectBuildConstraint(f *File, filename string, t *ast.File) {
+	f.GOOS, f.GOARCH = goosGoarchFromFilename(filename)
+
+	var plusBuild []constraint.Expr
+	for _, cg := range t.Comments {
+		if cg.Pos() >= t.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if constraint.IsGoBuild(c.Text) {
+				if expr, err := constraint.Parse(c.Text); err == nil {
+					f.Constraint = expr.String()
+					return
+				}
+			} else if constraint.IsPlusBuild(c.Text) {
+				if expr, err := constraint.Parse(c.Text); err == nil {
+					plusBuild = append(plusBuild, expr)
+				}
+			}
+		}
+	}
+	if len(plusBuild) > 0 {
+		expr := plusBuild[0]
+		for _, x := range plusBuild[1:] {
+			expr = &constraint.AndExpr{X: expr, Y: x}
+		}
+		f.Constraint = expr.String()
+	}
+}
+
+// goosGoarchFromFilename returns the GOOS/GOARCH of the filename suffix (e.g. *_linux.go, *_windows_amd64.go).
+// see go/build's goodOSArchFile
+func goosGoarchFromFilename(filename string) (goos string, goarch string) {
+	name := filepath.Base(filename)
+	name, _, _ = strings.Cut(name, ".")
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return "", ""
+	}
+	name = name[i:] // ignore everything before first _
+
+	l := strings.Split(name, "_")
+	if n := len(l); n > 0 && l[n-1] == "test" {
+		l = l[:n-1]
+	}
+	n := len(l)
+	if n >= 2 && knownOS[l[n-2]] && knownArch[l[n-1]] {
+		return l[n-2], l[n-1]
+	}
+	if n >= 1 && knownOS[l[n-1]] {
+		return l[n-1], ""
+	}
+	if n >= 1 && knownArch[l[n-1]] {
+		return "", l[n-1]
+	}
+	return "", ""
+}
+
+// from: internal/syslist
+
+var knownOS = map[string]bool{
+	"aix":       true,
+	"android":   true,
+	"darwin":    true,
+	"dragonfly": true,
+	"freebsd":   true,
+	"hurd":      true,
+	"illumos":   true,
+	"ios":       true,
+	"js":        true,
+	"linux":     true,
+	"nacl":      true,
+	"netbsd":    true,
+	"openbsd":   true,
+	"plan9":     true,
+	"solaris":   true,
+	"wasip1":    true,
+	"windows":   true,
+	"zos":       true,
+}
+
+var unixOS = map[string]bool{
+	"aix":       true,
+	"android":   true,
+	"darwin":    true,
+	"dragonfly": true,
+	"freebsd":   true,
+	"hurd":      true,
+	"illumos":   true,
+	"ios":       true,
+	"linux":     true,
+	"netbsd":    true,
+	"openbsd":   true,
+	"solaris":   true,
+}
+
+var knownArch = map[string]bool{
+	"386":         true,
+	"amd64":       true,
+	"amd64p32":    true,
+	"arm":         true,
+	"armbe":       true,
+	"arm64":       true,
+	"arm64be":     true,
+	"loong64":     true,
+	"mips":        true,
+	"mipsle":      true,
+	"mips64":      true,
+	"mips64le":    true,
+	"mips64p32":   true,
+	"mips64p32le": true,
+	"ppc":         true,
+	"ppc64":       true,
+	"ppc64le":     true,
+	"riscv":       true,
+	"riscv64":     true,
+	"s390":        true,
+	"s390x":       true,
+	"sparc":       true,
+	"sparc64":     true,
+	"wasm":        true,
+}
@@ -1,15 +1,32 @@
 package collect
 
-import "go/token"
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
 
 type Package struct {
+	Name       string         `json:"name,omitempty"`
+	ImportPath string         `json:"importpath,omitempty"`
+	Dir        string         `json:"dir,omitempty"` // the directory of the files (see emit.Key)
+	Fset       *token.FileSet `json:"-"`
+
 	Files      map[string]*File   `json:"-"`
 	Interfaces map[string]*Object `json:"interfaces"`
 	Functions  map[string]*Func   `json:"functions"`
 	Types      map[string]*Object `json:"types"`
+	Constants  map[string]*Const  `json:"constants,omitempty"`
+
+	Implements      map[string][]string `json:"implements,omitempty"`      // type -> interfaces (key is *T, if only the pointer type implements them)
+	Implementations map[string][]string `json:"implementations,omitempty"` // interface -> types (*T, if only the pointer type implements it)
 
 	FileNames []string `json:"filenames"`
 	Names     []string `json:"names"`
+
+	Diagnostics     []*Diagnostic `json:"diagnostics,omitempty"`
+	IncompleteFiles []string      `json:"incompletefiles,omitempty"` // files having syntax errors
 }
 
 func NewPackage() *Package {
@@ -18,15 +35,38 @@ func NewPackage() *Package {
 		Interfaces: map[string]*Object{},
 		Functions:  map[string]*Func{},
 		Types:      map[string]*Object{},
+		Constants:  map[string]*Const{},
 		Names:      []string{},
 	}
 }
 
 type File struct {
+	Package string `json:"package"` // package name
+
 	Interfaces map[string]*Object `json:"interfaces"`
 	Functions  map[string]*Func   `json:"functions"`
 	Types      map[string]*Object `json:"types"`
+	Constants  map[string]*Const  `json:"constants,omitempty"`
 	Names      []string           `json:"names"`
+
+	Diagnostics []*Diagnostic `json:"diagnostics,omitempty"`
+	Incomplete  bool          `json:"incomplete,omitempty"` // the file has syntax errors
+
+	Constraint string `json:"constraint,omitempty"` // //go:build expression
+	GOOS       string `json:"goos,omitempty"`       // GOOS of the filename suffix (e.g. *_linux.go)
+	GOARCH     string `json:"goarch,omitempty"`     // GOARCH of the filename suffix (e.g. *_amd64.go)
+
+	errorPositions []token.Pos // positions of syntax errors
+}
+
+// hasParseError reports whether the node includes syntax errors.
+func (f *File) hasParseError(node ast.Node) bool {
+	for _, pos := range f.errorPositions {
+		if node.Pos() <= pos && pos <= node.End() {
+			return true
+		}
+	}
+	return false
 }
 
 func NewFile() *File {
@@ -34,6 +74,7 @@ func NewFile() *File {
 		Interfaces: map[string]*Object{},
 		Functions:  map[string]*Func{},
 		Types:      map[string]*Object{},
+		Constants:  map[string]*Const{},
 		Names:      []string{},
 	}
 }
@@ -42,7 +83,10 @@ type Func struct {
 	Name string    `json:"name"`
 	Pos  token.Pos `json:"-"`
 
-	Recv string `json:"recv,omitempty"`
+	Recv            string   `json:"recv,omitempty"`            // receiver type (e.g. *Ob)
+	RecvName        string   `json:"recvname,omitempty"`        // receiver variable name (e.g. ob)
+	PointerReceiver bool     `json:"pointerreceiver,omitempty"` // receiver is a pointer (e.g. *Ob)
+	RecvTypeParams  []string `json:"recvtypeparams,omitempty"`  // type parameters of the receiver (e.g. T in *List[T])
 
 	Params     map[string]*Field `json:"params"`
 	ParamNames []string          `json:"paramnames"`
@@ -51,6 +95,32 @@ type Func struct {
 	ReturnNames []string          `json:"returnnames"`
 
 	Doc string `json:"doc"` // associated documentation; or nil (decl or spec?)
+
+	Platform string  `json:"platform,omitempty"` // build condition of the file (e.g. linux && cgo)
+	Variants []*Func `json:"variants,omitempty"` // definitions for the other platforms
+
+	Incomplete bool `json:"incomplete,omitempty"` // the declaration has syntax errors
+}
+
+// Signature returns the signature of the function without parameter names (e.g. func(int, string) (string, error)).
+func (fn *Func) Signature() string {
+	params := make([]string, len(fn.ParamNames))
+	for i, name := range fn.ParamNames {
+		params[i] = fn.Params[name].Type
+	}
+	results := make([]string, len(fn.ReturnNames))
+	for i, name := range fn.ReturnNames {
+		results[i] = fn.Returns[name].Type
+	}
+	return FormatSignature(params, results)
+}
+
+// Receiver returns the receiver type with its type parameters (e.g. *List[T]), or an empty string if fn is not a method.
+func (fn *Func) Receiver() string {
+	if len(fn.RecvTypeParams) == 0 {
+		return fn.Recv
+	}
+	return fn.Recv + "[" + strings.Join(fn.RecvTypeParams, ", ") + "]"
 }
 
 type Object struct {
@@ -59,22 +129,139 @@ type Object struct {
 	Token  token.Token `json:"-"`
 	Parent *Object     `json:"-"`
 
+	Alias        bool   `json:"alias,omitempty"`        // type <S> = <S>
+	Underlying   string `json:"underlying,omitempty"`   // type expression of the definition (not struct or interface)
+	ResolvedType string `json:"resolvedtype,omitempty"` // fully qualified underlying type (type-checked mode)
+	Target       string `json:"target,omitempty"`       // the original in-package type, if resolved
+
 	Fields     map[string]*Field `json:"fields,omitempty"`
 	FieldNames []string          `json:"fieldnames,omitempty"`
 
 	Methods     map[string]*Func `json:"methods,omitempty"`
 	MethodNames []string         `json:"methodnames,omitempty"`
 
+	ValueMethodSet   []string `json:"valuemethodset,omitempty"`   // methods of T (including promoted methods)
+	PointerMethodSet []string `json:"pointermethodset,omitempty"` // methods of *T (including promoted methods)
+	MethodSet        []string `json:"methodset,omitempty"`        // methods of the interface, including embedded ones (type-checked mode)
+
 	Doc     string `json:"doc"`     // associated documentation; or nil (decl or spec?)
 	Comment string `json:"comment"` // line comments; or nil
+
+	Platform string    `json:"platform,omitempty"` // build condition of the file (e.g. linux && cgo)
+	Variants []*Object `json:"variants,omitempty"` // definitions for the other platforms
+
+	Incomplete bool `json:"incomplete,omitempty"` // the declaration has syntax errors
 }
 
 type Field struct {
 	Name      string    `json:"name"`
 	Pos       token.Pos `json:"-"`
+	Type      string    `json:"type,omitempty"`      // type expression; or signature (method of interface)
+	Signature string    `json:"signature,omitempty"` // signature with the parameter names (method of interface, if they are named)
 	Embedded  bool      `json:"embedded"`
 	Anonymous *Object   `json:"annonymous,omitempty"`
+	Tag       string    `json:"tag,omitempty"` // struct tag (unquoted)
+
+	ResolvedType string  `json:"resolvedtype,omitempty"` // fully qualified type (type-checked mode)
+	Imported     *Object `json:"imported,omitempty"`     // declaration of the embedded type in the other package (type-checked mode)
+
+	Doc     string `json:"doc"`     // associated documentation; or nil
+	Comment string `json:"comment"` // line comments; or nil
+}
+
+type Const struct {
+	Name string    `json:"name"`
+	Pos  token.Pos `json:"-"`
+	Iota int       `json:"-"` // index in the const declaration
+
+	Type  string `json:"type,omitempty"`  // type expression; or empty (untyped)
+	Value string `json:"value,omitempty"` // value expression (repeated implicitly, if omitted)
+
+	ResolvedType string `json:"resolvedtype,omitempty"` // fully qualified type (type-checked mode)
+	Evaluated    string `json:"evaluated,omitempty"`    // evaluated value (type-checked mode)
 
 	Doc     string `json:"doc"`     // associated documentation; or nil
 	Comment string `json:"comment"` // line comments; or nil
+
+	Platform string   `json:"platform,omitempty"` // build condition of the file (e.g. linux && cgo)
+	Variants []*Const `json:"variants,omitempty"` // definitions for the other platforms
+
+	Incomplete bool `json:"incomplete,omitempty"` // the declaration has syntax errors
+}
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+type Diagnostic struct {
+	Pos      token.Pos `json:"-"`
+	Position string    `json:"position"`          // filename:line:column
+	Related  string    `json:"related,omitempty"` // related position (e.g. the previous definition)
+	Severity Severity  `json:"severity"`
+	Code     string    `json:"code"` // e.g. unexpected-decl, duplicate-symbol
+	Message  string    `json:"message"`
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Position == "" {
+		return fmt.Sprintf("%s: %s [%s]", d.Severity, d.Message, d.Code)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", d.Position, d.Severity, d.Message, d.Code)
+}
+
+// Lookup returns the symbol by the id (e.g. S, S.Nested, *Ob#MarshalJSON, S.Name), built with the default separators (Dot=".", Sharp="#").
+// The result is *Object, *Func, *Const or *Field. If it is not found, nil is returned.
+func (p *Package) Lookup(id string) interface{} {
+	if ob, ok := p.Types[id]; ok {
+		return ob
+	}
+	if ob, ok := p.Interfaces[id]; ok {
+		return ob
+	}
+	if fn, ok := p.Functions[id]; ok {
+		return fn
+	}
+	if c, ok := p.Constants[id]; ok {
+		return c
+	}
+
+	if recv, name, ok := strings.Cut(id, "#"); ok { // merged method
+		if ob, ok := p.Types[strings.TrimPrefix(recv, "*")]; ok {
+			if fn, ok := ob.Methods[name]; ok {
+				return fn
+			}
+		}
+		return nil
+	}
+
+	name, rest, ok := strings.Cut(id, ".")
+	if !ok {
+		return nil
+	}
+	ob, ok := p.Types[name]
+	if !ok {
+		if ob, ok = p.Interfaces[name]; !ok {
+			return nil
+		}
+	}
+	for {
+		name, rest, ok = strings.Cut(rest, ".")
+		field, found := ob.Fields[name]
+		if !found {
+			return nil
+		}
+		if !ok {
+			if field.Anonymous != nil {
+				return field.Anonymous
+			}
+			return field
+		}
+		if ob = field.Anonymous; ob == nil {
+			return nil
+		}
+	}
 }
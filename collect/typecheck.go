@@ -0,0 +1,298 @@
+package collect
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TypeChecker resolves the collected symbols with go/types (type-checked mode).
+type TypeChecker struct {
+	Fset      *token.FileSet
+	Importer  types.Importer
+	Collector *Collector // for collecting the declarations in other packages
+	Dir       string     // the directory used for finding other packages
+
+	Errors []error // type errors (these are not fatal)
+
+	imported map[string]*File
+}
+
+func (tc *TypeChecker) Check(p *Package, path string, files []*ast.File) (*types.Package, error) {
+	conf := &types.Config{
+		Importer: tc.Importer,
+		Error: func(err error) {
+			tc.Errors = append(tc.Errors, err)
+		},
+	}
+	pkg, err := conf.Check(path, tc.Fset, files, nil)
+	if pkg == nil {
+		return nil, err
+	}
+	scope := pkg.Scope()
+
+	for _, objects := range []map[string]*Object{p.Types, p.Interfaces} {
+		for name, ob := range objects {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			if ob.Underlying != "" {
+				ob.ResolvedType = tc.typeString(tn.Type().Underlying())
+			}
+			tc.resolveObject(pkg, ob, tn.Type())
+		}
+	}
+
+	for _, fn := range p.Functions {
+		f := lookupFunc(scope, fn)
+		if f == nil {
+			continue
+		}
+		sig := f.Type().(*types.Signature)
+		for i, name := range fn.ParamNames {
+			if i < sig.Params().Len() {
+				fn.Params[name].ResolvedType = tc.typeString(sig.Params().At(i).Type())
+			}
+		}
+		for i, name := range fn.ReturnNames {
+			if i < sig.Results().Len() {
+				fn.Returns[name].ResolvedType = tc.typeString(sig.Results().At(i).Type())
+			}
+		}
+	}
+
+	for name, s := range p.Constants {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if !ok {
+			continue
+		}
+		s.ResolvedType = tc.typeString(c.Type())
+		s.Evaluated = c.Val().ExactString()
+	}
+	return pkg, nil
+}
+
+func (tc *TypeChecker) typeString(typ types.Type) string {
+	return types.TypeString(typ, nil)
+}
+
+func (tc *TypeChecker) resolveObject(pkg *types.Package, ob *Object, typ types.Type) {
+	switch under := typ.Underlying().(type) {
+	case *types.Struct:
+		vars := make(map[string]*types.Var, under.NumFields())
+		for i := 0; i < under.NumFields(); i++ {
+			v := under.Field(i)
+			vars[v.Name()] = v
+		}
+		for _, id := range ob.FieldNames {
+			field := ob.Fields[id]
+			name := strings.TrimPrefix(field.Name, "*")
+			if i := strings.LastIndex(name, "."); i >= 0 {
+				name = name[i+1:] // pkg.T
+			}
+			v, ok := vars[name]
+			if !ok {
+				continue
+			}
+			field.ResolvedType = tc.typeString(v.Type())
+			if field.Anonymous != nil {
+				tc.resolveObject(pkg, field.Anonymous, v.Type())
+			} else if field.Embedded {
+				field.Imported = tc.importedObject(pkg, v.Type())
+			}
+		}
+	case *types.Interface:
+		ob.MethodSet = make([]string, under.NumMethods())
+		for i := 0; i < under.NumMethods(); i++ {
+			ob.MethodSet[i] = under.Method(i).Name()
+		}
+
+		methods := make(map[string]*types.Func, under.NumExplicitMethods())
+		for i := 0; i < under.NumExplicitMethods(); i++ {
+			m := under.ExplicitMethod(i)
+			methods[m.Name()] = m
+		}
+		j := 0 // embedded types are kept in source order
+		for _, id := range ob.FieldNames {
+			field := ob.Fields[id]
+			if !field.Embedded {
+				if m, ok := methods[field.Name]; ok {
+					field.ResolvedType = tc.typeString(m.Type())
+				}
+				continue
+			}
+			if j >= under.NumEmbeddeds() {
+				continue
+			}
+			embedded := under.EmbeddedType(j)
+			j++
+			field.ResolvedType = tc.typeString(embedded)
+			if field.Anonymous != nil {
+				tc.resolveObject(pkg, field.Anonymous, embedded)
+			} else {
+				field.Imported = tc.importedObject(pkg, embedded)
+			}
+		}
+	}
+}
+
+// importedObject returns the declaration of the type defined in the other package, collected from its source.
+func (tc *TypeChecker) importedObject(pkg *types.Package, typ types.Type) *Object {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg() == pkg {
+		return nil
+	}
+
+	path := named.Obj().Pkg().Path()
+	if tc.imported == nil {
+		tc.imported = map[string]*File{}
+	}
+	f, ok := tc.imported[path]
+	if !ok {
+		f = tc.collectImported(path)
+		tc.imported[path] = f
+	}
+	if f == nil {
+		return nil
+	}
+
+	name := named.Obj().Name()
+	if ob, ok := f.Types[name]; ok {
+		return ob
+	}
+	return f.Interfaces[name]
+}
+
+func (tc *TypeChecker) collectImported(path string) *File {
+	if tc.Collector == nil {
+		return nil
+	}
+	bp, err := build.Import(path, tc.Dir, 0)
+	if err != nil {
+		return nil
+	}
+
+	f := NewFile()
+	for _, name := range bp.GoFiles {
+		t, err := parser.ParseFile(tc.Fset, filepath.Join(bp.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, decl := range t.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				switch spec.Type.(type) {
+				case *ast.StructType, *ast.InterfaceType: // only embeddable types are needed
+					if err := tc.Collector.CollectFromTypeSpec(f, decl, spec); err != nil {
+						return nil
+					}
+				}
+			}
+		}
+	}
+	for _, ob := range f.Types {
+		ignoreExportedForObject(ob)
+	}
+	for _, ob := range f.Interfaces {
+		ignoreExportedForObject(ob)
+	}
+	return f
+}
+
+func lookupFunc(scope *types.Scope, fn *Func) *types.Func {
+	if fn.Recv == "" {
+		f, _ := scope.Lookup(fn.Name).(*types.Func)
+		return f
+	}
+
+	tn, ok := scope.Lookup(strings.TrimPrefix(fn.Recv, "*")).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	for i := 0; i < named.NumMethods(); i++ {
+		if m := named.Method(i); m.Name() == fn.Name {
+			return m
+		}
+	}
+	return nil
+}
+
+// detectImplementationsWithTypes is the type-checked version of detectImplementations.
+// The interfaces of the directly imported packages are also checked (e.g. fmt.Stringer).
+func detectImplementationsWithTypes(p *Package, pkg *types.Package) {
+	p.Implements = map[string][]string{}
+	p.Implementations = map[string][]string{}
+
+	var ifaces []*types.TypeName
+	var named []*types.TypeName
+	seen := map[string]bool{}
+	for _, name := range p.Names {
+		tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok || seen[name] || tn.IsAlias() || isGeneric(tn) {
+			continue
+		}
+		seen[name] = true
+		if types.IsInterface(tn.Type()) {
+			ifaces = append(ifaces, tn)
+		} else {
+			named = append(named, tn)
+		}
+	}
+
+	imports := pkg.Imports()
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Path() < imports[j].Path() })
+	for _, imported := range imports {
+		scope := imported.Scope()
+		for _, name := range scope.Names() { // sorted
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !tn.Exported() || isGeneric(tn) || !types.IsInterface(tn.Type()) {
+				continue
+			}
+			ifaces = append(ifaces, tn)
+		}
+	}
+
+	qualifier := types.RelativeTo(pkg)
+	for _, iface := range ifaces {
+		it := iface.Type().Underlying().(*types.Interface)
+		if it.NumMethods() == 0 || !it.IsMethodSet() {
+			continue
+		}
+		iname := types.TypeString(iface.Type(), qualifier)
+
+		for _, tn := range named {
+			impl := ""
+			if types.Implements(tn.Type(), it) {
+				impl = tn.Name()
+			} else if types.Implements(types.NewPointer(tn.Type()), it) {
+				impl = "*" + tn.Name()
+			} else {
+				continue
+			}
+			p.Implementations[iname] = append(p.Implementations[iname], impl)
+			p.Implements[impl] = append(p.Implements[impl], iname)
+		}
+	}
+}
+
+func isGeneric(tn *types.TypeName) bool {
+	named, ok := tn.Type().(*types.Named)
+	return ok && named.TypeParams().Len() > 0
+}
@@ -1,53 +1,259 @@
 package commentof
 
 import (
+	"errors"
+	"fmt"
 	"go/ast"
+	"go/parser"
+	"go/scanner"
 	"go/token"
+	"path/filepath"
+	"sort"
 
+	"github.com/podhmo/commentof/cache"
 	"github.com/podhmo/commentof/collect"
 )
 
+// Package collects the comments from the package.
+//
+// Deprecated: ast.Package is deprecated, use Files instead.
 func Package(fset *token.FileSet, t *ast.Package, options ...Option) (*collect.Package, error) {
-	b := defaultBuilder()
+	filenames := make([]string, 0, len(t.Files))
+	for filename := range t.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	files := make([]*ast.File, 0, len(filenames))
+	for _, filename := range filenames {
+		files = append(files, t.Files[filename])
+	}
+	return Files(fset, files, options...)
+}
+
+// File collects the comments from the file.
+func File(fset *token.FileSet, t *ast.File, options ...Option) (*collect.Package, error) {
+	return Files(fset, []*ast.File{t}, options...)
+}
+
+// Files collects the comments from the files of a package (e.g. the Syntax of packages.Package).
+// The metadata of the package can be passed with the options (e.g. WithImportPath).
+func Files(fset *token.FileSet, files []*ast.File, options ...Option) (*collect.Package, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files")
+	}
+
+	cfg := defaultConfig()
 	for _, opt := range options {
-		opt(b)
+		opt(cfg)
 	}
+	b := cfg.PackageBuilder
+	b.Fset = fset
 
-	c := &collect.Collector{Fset: fset, Dot: ".", Sharp: "#"}
+	c := newCollector(fset, cfg)
 	p := b.Package
-	if err := c.CollectFromPackage(p, t); err != nil {
+	p.Name, p.ImportPath, p.Fset = files[0].Name.Name, cfg.ImportPath, fset
+	p.Dir = filepath.Dir(fset.File(files[0].Pos()).Name())
+	if err := c.CollectFromFiles(b, files); err != nil {
 		return p, err
 	}
+
+	if cfg.TypeCheck != NoTypeCheck {
+		byName := make(map[string]*ast.File, len(files))
+		for _, t := range files {
+			byName[fset.File(t.Pos()).Name()] = t
+		}
+		platform := typeCheckPlatform(cfg)
+		targets := make([]*ast.File, 0, len(p.FileNames))
+		for _, filename := range p.FileNames { // skipped files are not included
+			if !platform.Match(p.Files[filename]) {
+				continue // the variants for the other platforms (e.g. Open in open_windows.go) are not type-checked
+			}
+			targets = append(targets, byName[filename])
+		}
+		if err := typeCheck(fset, c, cfg, targets); err != nil {
+			return p, err
+		}
+	}
 	return b.Build(), nil
 }
 
-func File(fset *token.FileSet, t *ast.File, options ...Option) (*collect.Package, error) {
-	b := defaultBuilder()
-	for _, opt := range options {
-		opt(b)
+func newCollector(fset *token.FileSet, cfg *config) *collect.Collector {
+	return &collect.Collector{
+		Fset: fset, Dot: ".", Sharp: "#", Strict: cfg.Strict, Report: cfg.Report, ParseErrors: cfg.ParseErrors,
+		Constants: collectConstants(cfg),
 	}
+}
+
+// collectConstants reports whether the constants are collected (see WithIncludeConstants).
+func collectConstants(cfg *config) bool {
+	return cfg.IncludeConstants || cfg.TypeCheck != NoTypeCheck
+}
 
-	c := &collect.Collector{Fset: fset, Dot: ".", Sharp: "#"}
-	f := collect.NewFile()
-	if err := c.CollectFromFile(f, t); err != nil {
-		return nil, err
+// Source collects the comments from the source code of the file (e.g. the unsaved buffer of an editor).
+// If src is nil, the file is read. The syntax errors are reported as diagnostics (see WithParseErrors).
+func Source(filename string, src []byte, options ...Option) (*collect.Package, error) {
+	fset := token.NewFileSet()
+	var input interface{}
+	if src != nil {
+		input = src
 	}
-	b.AddFile(f, fset.File(t.Pos()).Name())
-	return b.Build(), nil
+	t, err := parser.ParseFile(fset, filename, input, parser.ParseComments)
+	if !hasPackageClause(t) {
+		return nil, fmt.Errorf("parse file: %w", err)
+	}
+	return File(fset, t, append(options[:len(options):len(options)], WithParseErrors(err))...)
+}
+
+type config struct {
+	*collect.PackageBuilder
+
+	TypeCheck        TypeCheckMode
+	ImportPath       string
+	IncludeConstants bool
+
+	ParseErrors scanner.ErrorList
+
+	// for Dir and Load
+	IncludeTestFile bool
+	Overlay         map[string][]byte
+	Workers         int
+	Cache           *cache.Cache
 }
 
-func defaultBuilder() *collect.PackageBuilder {
-	return &collect.PackageBuilder{
-		Package:           collect.NewPackage(),
-		EnableMergeMethod: true,
-		IgnoreExported:    true,
+func defaultConfig() *config {
+	return &config{
+		PackageBuilder: &collect.PackageBuilder{
+			Package:           collect.NewPackage(),
+			EnableMergeMethod: true,
+			IgnoreExported:    true,
+		},
 	}
 }
 
-type Option func(*collect.PackageBuilder)
+type Option func(*config)
 
 func WithIncludeUnexported(ok bool) Option {
-	return func(b *collect.PackageBuilder) {
-		b.IgnoreExported = !ok
+	return func(c *config) {
+		c.IgnoreExported = !ok
+	}
+}
+
+func WithResolveTypeDefs(ok bool) Option {
+	return func(c *config) {
+		c.ResolveTypeDefs = ok
+	}
+}
+
+func WithDetectImplementations(ok bool) Option {
+	return func(c *config) {
+		c.DetectImplementations = ok
+	}
+}
+
+// WithPlatform selects the target platform. The files not built on the platform are skipped.
+// If the platform is not selected, the definitions for each platform are kept as variants.
+func WithPlatform(goos string, goarch string, tags ...string) Option {
+	return func(c *config) {
+		c.Platform = &collect.Platform{GOOS: goos, GOARCH: goarch, Tags: tags}
+	}
+}
+
+// WithConflictPolicy decides what happens when the same symbol is defined twice.
+func WithConflictPolicy(policy collect.ConflictPolicy) Option {
+	return func(c *config) {
+		c.ConflictPolicy = policy
+	}
+}
+
+// ParseConflictPolicy parses the name of the policy (variants, first, error).
+func ParseConflictPolicy(s string) (collect.ConflictPolicy, error) {
+	switch s {
+	case "", "variants":
+		return collect.ConflictKeepVariants, nil
+	case "first":
+		return collect.ConflictKeepFirst, nil
+	case "error":
+		return collect.ConflictError, nil
+	default:
+		return collect.ConflictKeepVariants, fmt.Errorf("unexpected conflict policy: %q (variants, first, error)", s)
+	}
+}
+
+// WithStrict turns the diagnostics (warning or error) into errors.
+func WithStrict(ok bool) Option {
+	return func(c *config) {
+		c.Strict = ok
+	}
+}
+
+// WithReport sets the callback called for each diagnostic, while collecting.
+// The diagnostics are also available as Package.Diagnostics.
+func WithReport(fn func(*collect.Diagnostic)) Option {
+	return func(c *config) {
+		c.Report = fn
+	}
+}
+
+// WithIncludeConstants collects the constants. They are always collected in the type-checked mode (see WithTypeCheck).
+func WithIncludeConstants(ok bool) Option {
+	return func(c *config) {
+		c.IncludeConstants = ok
+	}
+}
+
+// WithTypeCheck enables the type-checked mode (resolved types, evaluated constants, and so on).
+func WithTypeCheck(mode TypeCheckMode) Option {
+	return func(c *config) {
+		c.TypeCheck = mode
+	}
+}
+
+// WithImportPath sets the import path of the package (used in the type-checked mode).
+// If it is not set, the import path is guessed with go list.
+func WithImportPath(path string) Option {
+	return func(c *config) {
+		c.ImportPath = path
+	}
+}
+
+// WithParseErrors passes the error returned by go/parser together with the partial ASTs.
+// The files and the declarations including syntax errors are marked as incomplete.
+func WithParseErrors(err error) Option {
+	return func(c *config) {
+		var list scanner.ErrorList
+		var e *scanner.Error
+		if errors.As(err, &list) {
+			c.ParseErrors = append(c.ParseErrors, list...)
+		} else if errors.As(err, &e) {
+			c.ParseErrors = append(c.ParseErrors, e)
+		}
+	}
+}
+
+// WithIncludeTestFile includes *_test.go (used by Dir and Load).
+func WithIncludeTestFile(ok bool) Option {
+	return func(c *config) {
+		c.IncludeTestFile = ok
+	}
+}
+
+// WithOverlay replaces the contents of the files, for loading the unsaved files (used by Dir and Load).
+// The key is the path of the file, and a nil value means that the file is deleted.
+func WithOverlay(overlay map[string][]byte) Option {
+	return func(c *config) {
+		if c.Overlay == nil {
+			c.Overlay = make(map[string][]byte, len(overlay))
+		}
+		for path, src := range overlay {
+			c.Overlay[absPath(path)] = src
+		}
+	}
+}
+
+// WithWorkers sets the number of packages collected concurrently by LoadContext (default: GOMAXPROCS).
+func WithWorkers(n int) Option {
+	return func(c *config) {
+		c.Workers = n
 	}
 }
@@ -0,0 +1,131 @@
+// Package emit writes the collected packages in various formats.
+package emit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// Emitter writes the packages. Some formats write the output on Close (e.g. json-array).
+type Emitter interface {
+	Emit(p *collect.Package) error
+	Close() error
+}
+
+// Formats is the list of the supported formats.
+var Formats = []string{"json", "json-array", "json-object", "ndjson", "markdown", "jsonschema", "openapi", "typescript", "proto", "graphql", "gocode"}
+
+// New returns the emitter for the format.
+//
+//   - json: a JSON document per package (concatenated)
+//   - json-array: a JSON array of the packages
+//   - json-object: a JSON object of the packages, keyed by the import path (see Key)
+//   - ndjson: a JSON document per line
+//   - markdown: a markdown reference (see Markdown)
+//   - jsonschema: a JSON Schema per package, the named types are in $defs (see JSONSchema)
+//   - typescript: the TypeScript declarations with JSDoc (see TypeScript)
+//   - proto, graphql: the .proto file and the GraphQL schema (see Proto and GraphQL, and NewProto and NewGraphQL to map the types)
+//   - gocode: the Go file declaring the docs as map[string]string (see GoCode, and NewGoCode to name the package and the variable)
+//   - openapi: the OpenAPI paths of the handlers per package (see OpenAPI, and NewOpenAPI to select the handlers)
+func New(format string, w io.Writer) (Emitter, error) {
+	switch format {
+	case "", "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "	")
+		return &streamEmitter{enc: enc}, nil
+	case "ndjson":
+		return &streamEmitter{enc: json.NewEncoder(w)}, nil
+	case "json-array":
+		return &arrayEmitter{w: w, pkgs: []*collect.Package{}}, nil
+	case "json-object":
+		return &objectEmitter{w: w, pkgs: map[string]*collect.Package{}}, nil
+	case "markdown":
+		return &markdownEmitter{w: w}, nil
+	case "jsonschema":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "	")
+		return &jsonSchemaEmitter{enc: enc}, nil
+	case "typescript":
+		return &typescriptEmitter{w: w}, nil
+	case "proto":
+		return NewProto(w, nil), nil
+	case "graphql":
+		return NewGraphQL(w, nil), nil
+	case "gocode":
+		return NewGoCode(w, "", ""), nil
+	case "openapi":
+		return NewOpenAPI(w, nil), nil
+	default:
+		return nil, fmt.Errorf("unexpected format: %q (%s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Key returns the identifier of the package: the import path, or the directory (if it is unknown).
+func Key(p *collect.Package) string {
+	if p.ImportPath != "" {
+		return p.ImportPath
+	}
+	if strings.HasSuffix(p.Name, "_test") {
+		return p.Dir + "_test" // external test package
+	}
+	return p.Dir
+}
+
+type streamEmitter struct {
+	enc *json.Encoder
+}
+
+func (e *streamEmitter) Emit(p *collect.Package) error {
+	if err := e.enc.Encode(p); err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return nil
+}
+
+func (e *streamEmitter) Close() error { return nil }
+
+type arrayEmitter struct {
+	w    io.Writer
+	pkgs []*collect.Package
+}
+
+func (e *arrayEmitter) Emit(p *collect.Package) error {
+	e.pkgs = append(e.pkgs, p)
+	return nil
+}
+
+func (e *arrayEmitter) Close() error {
+	enc := json.NewEncoder(e.w)
+	enc.SetIndent("", "	")
+	if err := enc.Encode(e.pkgs); err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return nil
+}
+
+type objectEmitter struct {
+	w    io.Writer
+	pkgs map[string]*collect.Package
+}
+
+func (e *objectEmitter) Emit(p *collect.Package) error {
+	key := Key(p)
+	if _, ok := e.pkgs[key]; ok {
+		return fmt.Errorf("package %s is emitted twice", key)
+	}
+	e.pkgs[key] = p
+	return nil
+}
+
+func (e *objectEmitter) Close() error {
+	enc := json.NewEncoder(e.w)
+	enc.SetIndent("", "	")
+	if err := enc.Encode(e.pkgs); err != nil { // keys are sorted
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package emit
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// GoCode writes the Go file declaring the docs of the package as a map keyed by the ids (see Walk), e.g.
+//
+//	var Docs = map[string]string{
+//		"S.ExportedString": "ExportedString is exported string",
+//	}
+//
+// The doc and the comment are joined, and the symbols without them are omitted.
+// If pkgname is empty, the file is in the package itself, and if varname is empty, the variable is Docs.
+func GoCode(w io.Writer, p *collect.Package, pkgname string, varname string) error {
+	if pkgname == "" {
+		pkgname = p.Name
+	}
+	if varname == "" {
+		varname = "Docs"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by go-commentof; DO NOT EDIT.\n\npackage %s\n\n", pkgname)
+	from := p.ImportPath
+	if from == "" {
+		from = p.Name
+	}
+	fmt.Fprintf(&b, "// %s is the documentation of %s, keyed by the ids (e.g. S.Field, *S#Method, F.param).\n", varname, from)
+	fmt.Fprintf(&b, "var %s = map[string]string{\n", varname)
+	seen := map[string]bool{}
+	Walk(p, func(e *Entry) {
+		if seen[e.ID] {
+			return
+		}
+		seen[e.ID] = true
+		if doc := description(e.Doc, e.Comment); doc != "" {
+			fmt.Fprintf(&b, "%s: %s,\n", strconv.Quote(e.ID), strconv.Quote(doc))
+		}
+	})
+	b.WriteString("}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("format: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// NewGoCode returns the emitter writing the Go file of the package (see GoCode). Only one package can be emitted.
+func NewGoCode(w io.Writer, pkgname string, varname string) Emitter {
+	return &goCodeEmitter{w: w, pkgname: pkgname, varname: varname}
+}
+
+type goCodeEmitter struct {
+	w       io.Writer
+	pkgname string
+	varname string
+	emitted string
+}
+
+func (e *goCodeEmitter) Emit(p *collect.Package) error {
+	if e.emitted != "" {
+		return fmt.Errorf("gocode writes a single package, but %s is emitted after %s", Key(p), e.emitted)
+	}
+	e.emitted = Key(p)
+	return GoCode(e.w, p, e.pkgname, e.varname)
+}
+
+func (e *goCodeEmitter) Close() error { return nil }
@@ -0,0 +1,341 @@
+package emit
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// GraphQL writes the GraphQL schema of the package, with the docs and the comments as the descriptions.
+//
+// The exported structs become object types (the embedded structs are flattened, and the anonymous structs are the types named <Type><Field>),
+// and the exported interfaces become interfaces (the methods with parameters are the fields with arguments).
+// The slices are lists, the fields are non-null unless they are pointers, slices, maps or omitempty,
+// and the types not supported are skipped with a comment (see TypeMap to map them).
+func GraphQL(w io.Writer, p *collect.Package, typemap *TypeMap) error {
+	if typemap == nil {
+		typemap = &TypeMap{}
+	}
+	bw := bufio.NewWriter(w)
+	g := &graphql{w: bw, p: p, typemap: typemap, scalars: map[string]bool{}, inputs: map[string]bool{}}
+	g.printf("# package %s", p.Name)
+	if p.ImportPath != "" {
+		g.printf(" (%s)", p.ImportPath)
+	}
+	g.printf("\n")
+
+	for _, name := range p.Names {
+		if ob, ok := p.Types[name]; ok && isStruct(ob) && ast.IsExported(name) {
+			g.Type(ob.Name, ob, false)
+		} else if ob, ok := p.Interfaces[name]; ok && ast.IsExported(name) {
+			g.Interface(ob)
+		}
+	}
+
+	// the input types of the arguments (and of their fields)
+	for emitted := map[string]bool{}; len(emitted) < len(g.inputs); {
+		names := make([]string, 0, len(g.inputs))
+		for name := range g.inputs {
+			if !emitted[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			emitted[name] = true
+			g.Type(name+"Input", p.Types[name], true)
+		}
+	}
+
+	scalars := make([]string, 0, len(g.scalars))
+	for name := range g.scalars {
+		scalars = append(scalars, name)
+	}
+	sort.Strings(scalars)
+	for _, name := range scalars {
+		g.printf("\nscalar %s\n", name)
+	}
+	return bw.Flush()
+}
+
+type graphqlEmitter struct {
+	w       io.Writer
+	typemap *TypeMap
+	count   int
+}
+
+// NewGraphQL returns the emitter writing the GraphQL schema per package (see GraphQL).
+func NewGraphQL(w io.Writer, typemap *TypeMap) Emitter {
+	return &graphqlEmitter{w: w, typemap: typemap}
+}
+
+func (e *graphqlEmitter) Emit(p *collect.Package) error {
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, "\n"); err != nil {
+			return err
+		}
+	}
+	e.count++
+	return GraphQL(e.w, p, e.typemap)
+}
+
+func (e *graphqlEmitter) Close() error { return nil }
+
+type graphql struct {
+	w       *bufio.Writer
+	p       *collect.Package
+	typemap *TypeMap
+	scalars map[string]bool // the custom scalars used (e.g. Time)
+	inputs  map[string]bool // the structs used as the arguments
+
+	resolving map[string]bool
+}
+
+func (g *graphql) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.w, format, args...)
+}
+
+// Type writes the object type (or the input type) of the struct.
+func (g *graphql) Type(name string, ob *collect.Object, input bool) {
+	type nestedType struct {
+		name string
+		ob   *collect.Object
+	}
+	var nested []nestedType
+
+	g.printf("\n")
+	g.description("", description(ob.Doc, ob.Comment))
+	if input {
+		g.printf("input %s {\n", name)
+	} else {
+		g.printf("type %s {\n", name)
+	}
+	for _, f := range jsonFields(g.p, ob) {
+		fieldName := f.JSONName
+		if fieldName == f.Name {
+			fieldName = lowerCamelCase(fieldName)
+		}
+		var typ string
+		nullable := f.hasOpt("omitempty") || f.hasOpt("omitzero")
+		if f.Anonymous != nil && isStruct(f.Anonymous) {
+			base := name
+			if input {
+				base = strings.TrimSuffix(name, "Input")
+			}
+			typ = base + upperCamelCase(f.Name)
+			nested = append(nested, nestedType{name: typ, ob: f.Anonymous})
+			if input {
+				typ += "Input"
+			}
+		} else if expr, err := parser.ParseExpr(f.Type); err == nil {
+			t, null, ok := g.TypeOf(expr, input)
+			if ok {
+				typ = t
+				nullable = nullable || null
+			}
+		}
+		if typ == "" {
+			g.printf("\t# %s: unsupported type %s\n", fieldName, f.Type)
+			continue
+		}
+		if !nullable {
+			typ += "!"
+		}
+		g.description("\t", description(f.Doc, f.Comment))
+		g.printf("\t%s: %s\n", fieldName, typ)
+	}
+	g.printf("}\n")
+
+	for _, x := range nested {
+		if input {
+			g.Type(x.name+"Input", x.ob, true)
+		} else {
+			g.Type(x.name, x.ob, false)
+		}
+	}
+}
+
+func (g *graphql) Interface(ob *collect.Object) {
+	g.printf("\n")
+	g.description("", description(ob.Doc, ob.Comment))
+	g.printf("interface %s {\n", ob.Name)
+	for _, id := range ob.FieldNames {
+		f := ob.Fields[id]
+		if f.Embedded || f.Anonymous != nil {
+			continue
+		}
+		sig := f.Signature // with the parameter names
+		if sig == "" {
+			sig = f.Type
+		}
+		expr, err := parser.ParseExpr(sig)
+		if err != nil {
+			continue
+		}
+		fn, ok := expr.(*ast.FuncType)
+		if !ok {
+			continue
+		}
+
+		var args []string
+		unsupported := false
+		for _, param := range fn.Params.List {
+			if types.ExprString(param.Type) == "context.Context" {
+				continue
+			}
+			typ, nullable, ok := g.TypeOf(param.Type, true)
+			if !ok {
+				unsupported = true
+				break
+			}
+			if !nullable {
+				typ += "!"
+			}
+			if len(param.Names) == 0 {
+				args = append(args, fmt.Sprintf("arg%d: %s", len(args), typ))
+			}
+			for _, name := range param.Names {
+				args = append(args, fmt.Sprintf("%s: %s", name.Name, typ))
+			}
+		}
+
+		var result ast.Expr
+		if fn.Results != nil {
+			for _, r := range fn.Results.List {
+				if types.ExprString(r.Type) != "error" {
+					result = r.Type
+					break
+				}
+			}
+		}
+		name := lowerCamelCase(f.Name)
+		if result == nil || unsupported {
+			g.printf("\t# %s: unsupported signature %s\n", name, f.Type)
+			continue
+		}
+		typ, nullable, ok := g.TypeOf(result, false)
+		if !ok {
+			g.printf("\t# %s: unsupported signature %s\n", name, f.Type)
+			continue
+		}
+		if !nullable {
+			typ += "!"
+		}
+
+		g.description("\t", description(f.Doc, f.Comment))
+		if len(args) > 0 {
+			g.printf("\t%s(%s): %s\n", name, strings.Join(args, ", "), typ)
+		} else {
+			g.printf("\t%s: %s\n", name, typ)
+		}
+	}
+	g.printf("}\n")
+}
+
+// TypeOf returns the GraphQL type of the type expression (without !), and whether it is nullable.
+// If input is true, the structs are the input types (<Type>Input), and the interfaces are not supported.
+func (g *graphql) TypeOf(expr ast.Expr, input bool) (string, bool, bool) {
+	if name, ok := g.typemap.GraphQL[types.ExprString(expr)]; ok {
+		return name, false, true
+	}
+	switch x := expr.(type) {
+	case *ast.Ident:
+		switch x.Name {
+		case "bool":
+			return "Boolean", false, true
+		case "string":
+			return "String", false, true
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune":
+			return "Int", false, true
+		case "float32", "float64":
+			return "Float", false, true
+		case "any":
+			g.scalars["JSON"] = true
+			return "JSON", true, true
+		}
+		if _, ok := g.p.Interfaces[x.Name]; ok && !input {
+			return x.Name, true, true
+		}
+		ob, ok := g.p.Types[x.Name]
+		if !ok {
+			return "", false, false
+		}
+		if isStruct(ob) {
+			if input {
+				g.inputs[x.Name] = true
+				return x.Name + "Input", false, true
+			}
+			return x.Name, false, true
+		}
+		if ob.Underlying == "" || g.resolving[x.Name] {
+			return "", false, false
+		}
+		if g.resolving == nil {
+			g.resolving = map[string]bool{}
+		}
+		g.resolving[x.Name] = true
+		defer delete(g.resolving, x.Name)
+		underlying, err := parser.ParseExpr(ob.Underlying)
+		if err != nil {
+			return "", false, false
+		}
+		return g.TypeOf(underlying, input)
+	case *ast.StarExpr:
+		typ, _, ok := g.TypeOf(x.X, input)
+		return typ, true, ok
+	case *ast.ParenExpr:
+		return g.TypeOf(x.X, input)
+	case *ast.ArrayType:
+		if ident, ok := x.Elt.(*ast.Ident); ok && ident.Name == "byte" && x.Len == nil {
+			return "String", false, true // base64
+		}
+		typ, nullable, ok := g.TypeOf(x.Elt, input)
+		if !ok {
+			return "", false, false
+		}
+		if !nullable {
+			typ += "!"
+		}
+		return "[" + typ + "]", x.Len == nil, true
+	case *ast.MapType:
+		g.scalars["JSON"] = true
+		return "JSON", true, true
+	case *ast.SelectorExpr:
+		switch exprString(x) {
+		case "time.Time":
+			g.scalars["Time"] = true
+			return "Time", false, true
+		case "time.Duration":
+			g.scalars["Duration"] = true
+			return "Duration", false, true
+		}
+		return "", false, false
+	case *ast.InterfaceType:
+		g.scalars["JSON"] = true
+		return "JSON", true, true
+	default:
+		return "", false, false
+	}
+}
+
+func (g *graphql) description(indent string, doc string) {
+	if doc == "" {
+		return
+	}
+	if !strings.Contains(doc, "\n") {
+		g.printf("%s%q\n", indent, doc)
+		return
+	}
+	g.printf("%s\"\"\"\n", indent)
+	for _, line := range strings.Split(strings.ReplaceAll(doc, `"""`, `\"""`), "\n") {
+		g.printf("%s%s\n", indent, line)
+	}
+	g.printf("%s\"\"\"\n", indent)
+}
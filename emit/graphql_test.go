@@ -0,0 +1,78 @@
+package emit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLMapping(t *testing.T) {
+	const src = `package api
+
+import (
+	"context"
+	"time"
+)
+
+// Item is an item.
+type Item struct {
+	ID      int64             ` + "`json:\"id\"`" + `
+	Note    *string           ` + "`json:\"note\"`" + `
+	Tags    []string          ` + "`json:\"tags,omitempty\"`" + `
+	Labels  map[string]string ` + "`json:\"labels\"`" + `
+	Created time.Time         ` + "`json:\"created\"`" + `
+}
+
+// Service is the service.
+type Service interface {
+	Get(ctx context.Context, id int64) (*Item, error)
+	Between(from, to int64) ([]Item, error)
+	Unnamed(int64, string) (Item, error)
+	Delete(id int64) error
+	Watch(ch chan int) (*Item, error)
+	At(t time.Time) (*Item, error)
+	Create(ctx context.Context, item *Item) (*Item, error)
+	Ping(ctx context.Context) (*Item, error)
+}
+`
+	p := source(t, src)
+
+	cases := []struct {
+		name     string
+		typemap  *TypeMap
+		want     string
+		notWants []string
+	}{
+		{name: "the parameter names", want: "\tget(id: Int!): Item\n"},
+		{name: "the grouped parameter names", want: "\tbetween(from: Int!, to: Int!): [Item!]\n"},
+		{name: "the unnamed parameters", want: "\tunnamed(arg0: Int!, arg1: String!): Item!\n"},
+		{name: "error only", want: "\t# delete: unsupported signature func(int64) error\n"},
+		{name: "unsupported parameter", want: "\t# watch: unsupported signature func(chan int) (*Item, error)\n"},
+		{name: "input type", want: "\tcreate(item: ItemInput): Item\n"},
+		{name: "no parameters", want: "\tping: Item\n"},
+		{name: "pointer is nullable", want: "\tnote: String\n"},
+		{name: "omitempty slice", want: "\ttags: [String!]\n"},
+		{name: "map", want: "\tlabels: JSON\n"},
+		{name: "time", want: "\tcreated: Time!\n"},
+		{name: "custom scalar", want: "\nscalar Time\n"},
+		{name: "mapped type", typemap: &TypeMap{GraphQL: map[string]string{"time.Time": "DateTime"}}, want: "\tat(t: DateTime!): Item\n", notWants: []string{"scalar Time"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := GraphQL(&buf, p, c.typemap); err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			got := buf.String()
+			if !strings.Contains(got, c.want) {
+				t.Errorf("want %q in\n%s", c.want, got)
+			}
+			for _, s := range c.notWants {
+				if strings.Contains(got, s) {
+					t.Errorf("unexpected %q in\n%s", s, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package emit
+
+import (
+	"fmt"
+	"go/token"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// Site generates the static HTML documentation (an index of the packages, and a page per package).
+// The anchors are the ids of the symbols (e.g. S, S.Nested, *Ob#MarshalJSON, F.x, F.ret0).
+type Site struct {
+	Dir string // output directory
+
+	// SourceURL is the link of the source position, {file} and {line} are replaced (e.g. https://github.com/foo/bar/blob/main/{file}#L{line}).
+	// If it is empty, the links are the relative paths to the local files.
+	SourceURL string
+}
+
+type sitePackage struct {
+	*collect.Package
+	Key  string
+	Path string // the page (relative to the site)
+}
+
+// Write writes the index page and the package pages.
+func (s *Site) Write(pkgs []*collect.Package) error {
+	pages := make([]*sitePackage, 0, len(pkgs))
+	seen := map[string]bool{}
+	for _, p := range pkgs {
+		key := Key(p)
+		if seen[key] {
+			return fmt.Errorf("package %s is emitted twice", key)
+		}
+		seen[key] = true
+		path := strings.TrimLeft(filepath.ToSlash(key), "/")
+		path = strings.NewReplacer(":", "_", "..", "_").Replace(path)
+		pages = append(pages, &sitePackage{Package: p, Key: key, Path: path + "/index.html"})
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Key < pages[j].Key })
+
+	if err := s.render(filepath.Join(s.Dir, "index.html"), "index", map[string]interface{}{"Packages": pages}); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		filename := filepath.Join(s.Dir, filepath.FromSlash(page.Path))
+		if err := s.render(filename, "package", page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Site) render(filename string, name string, data interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	root, err := filepath.Rel(filepath.Dir(filename), s.Dir)
+	if err != nil {
+		return err
+	}
+	tmpl := template.Must(template.New("").Funcs(FuncMap()).Funcs(template.FuncMap{
+		"root": func() string { return filepath.ToSlash(root) },
+		"source": func(p *collect.Package, pos token.Pos) string {
+			return s.sourceLink(filename, p, pos)
+		},
+		"anchor": func(id string) string { return "#" + strings.ReplaceAll(id, "#", "%23") },
+		"join":   func(parts ...string) string { return strings.Join(parts, "") },
+		"dict": func(kvs ...interface{}) map[string]interface{} {
+			m := make(map[string]interface{}, len(kvs)/2)
+			for i := 0; i+1 < len(kvs); i += 2 {
+				m[kvs[i].(string)] = kvs[i+1]
+			}
+			return m
+		},
+		"fieldTitle": func(kind string) string {
+			if kind == "interface" {
+				return "method"
+			}
+			return "field"
+		},
+		"recv": func(fn *collect.Func) string {
+			if fn.Recv == "" {
+				return ""
+			}
+			return fn.Recv + "#"
+		},
+	}).Parse(siteTemplate))
+	if err := tmpl.ExecuteTemplate(f, name, data); err != nil {
+		return fmt.Errorf("render %s: %w", filename, err)
+	}
+	return f.Close()
+}
+
+func (s *Site) sourceLink(page string, p *collect.Package, pos token.Pos) string {
+	if p.Fset == nil || !pos.IsValid() {
+		return ""
+	}
+	position := p.Fset.Position(pos)
+	if s.SourceURL != "" {
+		file := position.Filename
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+				file = rel
+			}
+		}
+		return strings.NewReplacer("{file}", filepath.ToSlash(file), "{line}", strconv.Itoa(position.Line)).Replace(s.SourceURL)
+	}
+
+	abspage, err1 := filepath.Abs(filepath.Dir(page))
+	absfile, err2 := filepath.Abs(position.Filename)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(abspage, absfile)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s#L%d", filepath.ToSlash(rel), position.Line)
+}
+
+const siteTemplate = `
+{{- define "head" -}}
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ . }}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 0 auto; padding: 1em; line-height: 1.5; }
+table { border-collapse: collapse; margin: 0.5em 0 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; vertical-align: top; }
+code, pre { background: #f4f4f4; }
+pre { padding: 0.5em; }
+.doc { white-space: pre-wrap; }
+.source { font-size: small; margin-left: 0.5em; }
+</style>
+</head>
+<body>
+{{- end -}}
+
+{{- define "index" -}}
+{{ template "head" "packages" }}
+<h1>packages</h1>
+<table>
+<tr><th>package</th><th>name</th><th>symbols</th></tr>
+{{- range .Packages }}
+<tr><td><a href="{{ .Path }}">{{ .Key }}</a></td><td>{{ .Name }}</td><td>{{ len .Names }}</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+{{ end -}}
+
+{{- define "fields" -}}
+{{- $prefix := .Prefix }}{{ $fields := .Fields }}{{ with .IDs }}
+<table>
+<tr><th>{{ $.Title }}</th><th>type</th><th>doc</th><th>comment</th></tr>
+{{- range . }}{{ $f := index $fields . }}
+<tr{{ if not $f.Anonymous }} id="{{ join $prefix "." . }}"{{ end }}><td>{{ $f.Name }}{{ if $f.Embedded }} (embedded){{ end }}</td><td><code>{{ $f.Type }}</code></td><td class="doc">{{ trim $f.Doc }}</td><td class="doc">{{ trim $f.Comment }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+{{- end -}}
+
+{{- define "func-title" -}}
+func {{ if .Func.Recv }}({{ .Func.Receiver }}) {{ end }}{{ .Func.Name }}<a class="source" href="{{ source .Package .Func.Pos }}">source</a>
+{{- end -}}
+
+{{- define "object-title" -}}
+{{ .Kind }} {{ .Object.Name }}<a class="source" href="{{ source .Package .Object.Pos }}">source</a>
+{{- end -}}
+
+{{- define "func" -}}
+{{- $id := join (recv .Func) .Func.Name }}
+{{- if .Nested }}
+<h4 id="{{ $id }}">{{ template "func-title" . }}</h4>
+{{- else }}
+<h3 id="{{ $id }}">{{ template "func-title" . }}</h3>
+{{- end }}
+<pre>func {{ if .Func.Recv }}({{ .Func.RecvName }} {{ .Func.Receiver }}) {{ end }}{{ .Func.Name }}{{ slice .Func.Signature 4 }}</pre>
+{{- with trim .Func.Doc }}
+<p class="doc">{{ . }}</p>
+{{- end }}
+{{- template "fields" (dict "Title" "parameter" "Prefix" $id "IDs" .Func.ParamNames "Fields" .Func.Params) }}
+{{- template "fields" (dict "Title" "return" "Prefix" $id "IDs" .Func.ReturnNames "Fields" .Func.Returns) }}
+{{- end -}}
+
+{{- define "object" -}}
+{{- if .Nested }}
+<h4 id="{{ .Object.Name }}">{{ template "object-title" . }}</h4>
+{{- else }}
+<h3 id="{{ .Object.Name }}">{{ template "object-title" . }}</h3>
+{{- end }}
+{{- with trim .Object.Doc }}
+<p class="doc">{{ . }}</p>
+{{- end }}
+{{- with trim .Object.Comment }}
+<p class="doc">{{ . }}</p>
+{{- end }}
+{{- with .Object.Underlying }}
+<pre>type {{ $.Object.Name }} {{ if $.Object.Alias }}= {{ end }}{{ . }}</pre>
+{{- end }}
+{{- template "fields" (dict "Title" (.Kind | fieldTitle) "Prefix" .Object.Name "IDs" .Object.FieldNames "Fields" .Object.Fields) }}
+{{- $p := .Package }}
+{{- range methods .Object }}
+{{- template "func" (dict "Nested" true "Package" $p "Func" .) }}
+{{- end }}
+{{- $kind := .Kind }}
+{{- range fields .Object }}{{ with .Anonymous }}
+{{- template "object" (dict "Nested" true "Package" $p "Kind" $kind "Object" .) }}
+{{- end }}{{ end }}
+{{- end -}}
+
+{{- define "package" -}}
+{{ template "head" .Key }}
+<p><a href="{{ root }}/index.html">packages</a></p>
+<h1>package {{ .Name }}</h1>
+{{- with .ImportPath }}
+<pre>import "{{ . }}"</pre>
+{{- end }}
+{{- $p := .Package }}
+{{- $symbols := symbols .Package }}
+<ul>
+{{- range $symbols }}
+<li><a href="{{ anchor .ID }}">{{ .Kind }} {{ .ID }}</a></li>
+{{- end }}
+</ul>
+{{- range $symbols }}
+{{- if .Object }}
+{{- template "object" (dict "Nested" false "Package" $p "Kind" .Kind "Object" .Object) }}
+{{- else if .Func }}
+{{- template "func" (dict "Nested" false "Package" $p "Func" .Func) }}
+{{- else if .Const }}
+<h3 id="{{ .ID }}">const {{ .ID }}<a class="source" href="{{ source $p .Const.Pos }}">source</a></h3>
+<pre>const {{ .Const.Name }}{{ with .Const.Type }} {{ . }}{{ end }}{{ with .Const.Value }} = {{ . }}{{ end }}</pre>
+{{- with trim .Const.Doc }}
+<p class="doc">{{ . }}</p>
+{{- end }}
+{{- with trim .Const.Comment }}
+<p class="doc">{{ . }}</p>
+{{- end }}
+{{- end }}
+{{- end }}
+</body>
+</html>
+{{ end -}}
+`
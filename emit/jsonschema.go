@@ -0,0 +1,348 @@
+package emit
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// Schema is a JSON Schema (draft 2020-12), only the keywords used by JSONSchema.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Enum                 []interface{} `json:"enum,omitempty"`
+	Items                *Schema       `json:"items,omitempty"`
+	Properties           *Properties   `json:"properties,omitempty"`
+	Required             []string      `json:"required,omitempty"`
+	AdditionalProperties *Schema       `json:"additionalProperties,omitempty"`
+	ContentEncoding      string        `json:"contentEncoding,omitempty"`
+
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Properties is the properties of the object schema, in the source order.
+type Properties struct {
+	Names   []string
+	Schemas map[string]*Schema
+}
+
+func (p *Properties) Set(name string, s *Schema) {
+	if _, ok := p.Schemas[name]; !ok {
+		p.Names = append(p.Names, name)
+	}
+	p.Schemas[name] = s
+}
+
+func (p *Properties) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, name := range p.Names {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		k, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(p.Schemas[name])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteString(":")
+		b.Write(v)
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+// JSONSchema returns the JSON Schema of the package. The named types (structs, and the defined types) are in $defs.
+//
+// The property names and the required properties follow the json tags (encoding/json), the embedded structs are flattened,
+// the anonymous structs are inlined, and the descriptions are the docs and the comments.
+// The typed constants of a defined type become its enum.
+func JSONSchema(p *collect.Package) *Schema {
+	c := &jsonSchema{p: p, ref: "#/$defs/", resolving: map[string]bool{}}
+	root := &Schema{
+		Schema: "https://json-schema.org/draft/2020-12/schema",
+		ID:     p.ImportPath,
+		Defs:   map[string]*Schema{},
+	}
+	for _, name := range p.Names {
+		ob, ok := p.Types[name]
+		if !ok {
+			continue
+		}
+		if s := c.Object(ob); s != nil {
+			root.Defs[name] = s
+		}
+	}
+	return root
+}
+
+type jsonSchema struct {
+	p         *collect.Package
+	ref       string          // prefix of $ref (e.g. #/$defs/)
+	resolving map[string]bool // the defined types being resolved (e.g. type A B; type B A)
+}
+
+func (c *jsonSchema) Object(ob *collect.Object) *Schema {
+	if ob.Underlying == "" { // struct
+		s := &Schema{Type: "object", Properties: &Properties{Schemas: map[string]*Schema{}}}
+		c.fields(s, ob, map[string]bool{ob.Name: true})
+		s.Description = description(ob.Doc, ob.Comment)
+		return s
+	}
+
+	expr, err := parser.ParseExpr(ob.Underlying)
+	if err != nil {
+		return nil
+	}
+	s := c.Expr(expr)
+	if s == nil {
+		return nil // e.g. func, chan
+	}
+	if ob.Alias {
+		if s.Ref != "" {
+			s = &Schema{Ref: s.Ref} // not to modify the shared schema
+		}
+	} else if s.Ref == "" {
+		_, s.Enum = enum(c.p, ob.Name)
+	}
+	s.Description = description(ob.Doc, ob.Comment)
+	return s
+}
+
+// fields sets the properties of the struct. The fields of the embedded structs are promoted (the outer fields win, as encoding/json).
+func (c *jsonSchema) fields(s *Schema, ob *collect.Object, seen map[string]bool) {
+	var embedded []*collect.Object
+	for _, id := range ob.FieldNames {
+		f := ob.Fields[id]
+		name, opts := jsonTag(f)
+		if name == "-" && opts == "" {
+			continue
+		}
+		if f.Embedded && name == "" {
+			if target := lookupStruct(c.p, f.Type); target != nil && !seen[target.Name] {
+				embedded = append(embedded, target)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == "" || !token.IsExported(f.Name) {
+			continue
+		}
+
+		var fs *Schema
+		if f.Anonymous != nil {
+			if f.Anonymous.Token == token.INTERFACE {
+				fs = &Schema{}
+			} else {
+				fs = &Schema{Type: "object", Properties: &Properties{Schemas: map[string]*Schema{}}}
+				c.fields(fs, f.Anonymous, seen)
+			}
+		} else if expr, err := parser.ParseExpr(f.Type); err == nil {
+			fs = c.Expr(expr)
+		}
+		if fs == nil {
+			continue // e.g. func, chan
+		}
+		if strings.Contains(","+opts+",", ",string,") {
+			fs = &Schema{Type: "string"}
+		}
+		if fs.Ref != "" {
+			fs = &Schema{Ref: fs.Ref}
+		}
+		fs.Description = description(f.Doc, f.Comment)
+
+		s.Properties.Set(name, fs)
+		if !strings.Contains(","+opts+",", ",omitempty,") && !strings.Contains(","+opts+",", ",omitzero,") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	for _, target := range embedded {
+		seen[target.Name] = true
+		inner := &Schema{Properties: &Properties{Schemas: map[string]*Schema{}}}
+		c.fields(inner, target, seen)
+		required := map[string]bool{}
+		for _, name := range inner.Required {
+			required[name] = true
+		}
+		for _, name := range inner.Properties.Names {
+			if _, ok := s.Properties.Schemas[name]; ok {
+				continue
+			}
+			s.Properties.Set(name, inner.Properties.Schemas[name])
+			if required[name] {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+}
+
+// Expr returns the schema of the type expression, or nil if it cannot be encoded as JSON.
+func (c *jsonSchema) Expr(expr ast.Expr) *Schema {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		switch x.Name {
+		case "bool":
+			return &Schema{Type: "boolean"}
+		case "string":
+			return &Schema{Type: "string"}
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune":
+			return &Schema{Type: "integer"}
+		case "float32", "float64":
+			return &Schema{Type: "number"}
+		case "any":
+			return &Schema{}
+		case "error":
+			return nil
+		}
+		if ob, ok := c.p.Types[x.Name]; ok {
+			if c.resolving[x.Name] {
+				return &Schema{Ref: c.ref + x.Name}
+			}
+			c.resolving[x.Name] = true
+			defer delete(c.resolving, x.Name)
+			if c.Object(ob) != nil {
+				return &Schema{Ref: c.ref + x.Name}
+			}
+			return nil
+		}
+		return &Schema{}
+	case *ast.StarExpr:
+		return c.Expr(x.X)
+	case *ast.ParenExpr:
+		return c.Expr(x.X)
+	case *ast.ArrayType:
+		if ident, ok := x.Elt.(*ast.Ident); ok && ident.Name == "byte" && x.Len == nil {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		items := c.Expr(x.Elt)
+		if items == nil {
+			return nil
+		}
+		return &Schema{Type: "array", Items: items}
+	case *ast.MapType:
+		values := c.Expr(x.Value)
+		if values == nil {
+			return nil
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}
+	case *ast.SelectorExpr:
+		switch exprString(x) {
+		case "time.Time":
+			return &Schema{Type: "string", Format: "date-time"}
+		case "time.Duration":
+			return &Schema{Type: "integer"}
+		}
+		return &Schema{}
+	case *ast.IndexExpr, *ast.IndexListExpr, *ast.InterfaceType, *ast.StructType:
+		return &Schema{}
+	default: // func, chan
+		return nil
+	}
+}
+
+// lookupStruct returns the in-package struct of the (embedded) type expression.
+func lookupStruct(p *collect.Package, typ string) *collect.Object {
+	ob, ok := p.Types[strings.TrimPrefix(typ, "*")]
+	if !ok || ob.Underlying != "" {
+		return nil
+	}
+	return ob
+}
+
+// enum returns the typed constants of the defined type and their values, if all of them are literals.
+func enum(p *collect.Package, typename string) ([]*collect.Const, []interface{}) {
+	var consts []*collect.Const
+	var values []interface{}
+	for _, name := range p.Names {
+		k, ok := p.Constants[name]
+		if !ok || k.Type != typename {
+			continue
+		}
+		value := k.Evaluated
+		if value == "" {
+			value = k.Value
+		}
+		expr, err := parser.ParseExpr(value)
+		if err != nil {
+			return nil, nil
+		}
+		lit, ok := expr.(*ast.BasicLit)
+		if !ok {
+			return nil, nil
+		}
+		switch lit.Kind {
+		case token.STRING:
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return nil, nil
+			}
+			values = append(values, s)
+		case token.INT:
+			values = append(values, json.Number(constant.MakeFromLiteral(lit.Value, lit.Kind, 0).ExactString())) // e.g. 0x10 -> 16
+		case token.FLOAT:
+			f, _ := constant.Float64Val(constant.MakeFromLiteral(lit.Value, lit.Kind, 0))
+			values = append(values, f)
+		default:
+			return nil, nil
+		}
+		consts = append(consts, k)
+	}
+	return consts, values
+}
+
+func jsonTag(f *collect.Field) (name string, opts string) {
+	tag := reflect.StructTag(f.Tag).Get("json")
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+func exprString(x *ast.SelectorExpr) string {
+	if ident, ok := x.X.(*ast.Ident); ok {
+		return ident.Name + "." + x.Sel.Name
+	}
+	return ""
+}
+
+// description joins the doc and the comment.
+func description(doc string, comment string) string {
+	doc, comment = strings.TrimSpace(doc), strings.TrimSpace(comment)
+	if doc == "" || doc == comment {
+		return comment
+	}
+	if comment == "" {
+		return doc
+	}
+	return doc + "\n" + comment
+}
+
+type jsonSchemaEmitter struct {
+	enc *json.Encoder
+}
+
+func (e *jsonSchemaEmitter) Emit(p *collect.Package) error {
+	return e.enc.Encode(JSONSchema(p))
+}
+
+func (e *jsonSchemaEmitter) Close() error { return nil }
@@ -0,0 +1,228 @@
+package emit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// Markdown writes the package as a markdown reference.
+// Methods are nested under their receiver types, and the fields, parameters and return values are rendered as tables.
+func Markdown(w io.Writer, p *collect.Package) error {
+	bw := bufio.NewWriter(w)
+	m := &markdown{w: bw}
+	m.Package(p)
+	return bw.Flush()
+}
+
+type markdownEmitter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *markdownEmitter) Emit(p *collect.Package) error {
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, "\n---\n\n"); err != nil {
+			return err
+		}
+	}
+	e.count++
+	return Markdown(e.w, p)
+}
+
+func (e *markdownEmitter) Close() error { return nil }
+
+type markdown struct {
+	w *bufio.Writer
+}
+
+func (m *markdown) printf(format string, args ...interface{}) {
+	fmt.Fprintf(m.w, format, args...)
+}
+
+func (m *markdown) Package(p *collect.Package) {
+	m.printf("# package %s\n\n", p.Name)
+	if p.ImportPath != "" {
+		m.printf("```go\nimport %q\n```\n\n", p.ImportPath)
+	}
+
+	var types, interfaces, funcs, consts []string
+	for _, name := range p.Names {
+		if _, ok := p.Types[name]; ok {
+			types = append(types, name)
+		} else if _, ok := p.Interfaces[name]; ok {
+			interfaces = append(interfaces, name)
+		} else if _, ok := p.Functions[name]; ok {
+			funcs = append(funcs, name)
+		} else if _, ok := p.Constants[name]; ok {
+			consts = append(consts, name)
+		}
+	}
+
+	if len(consts) > 0 {
+		m.printf("## Constants\n\n")
+		m.printf("| name | type | value | doc | comment |\n|---|---|---|---|---|\n")
+		for _, name := range consts {
+			c := p.Constants[name]
+			typ := code(c.Type)
+			if c.Type == "" {
+				typ = "(untyped)"
+			}
+			m.printf("| %s | %s | %s | %s | %s |\n", cell(c.Name), typ, code(c.Value), cell(c.Doc), cell(c.Comment))
+		}
+		m.printf("\n")
+	}
+	if len(interfaces) > 0 {
+		m.printf("## Interfaces\n\n")
+		for _, name := range interfaces {
+			m.Object("interface", p.Interfaces[name])
+		}
+	}
+	if len(types) > 0 {
+		m.printf("## Types\n\n")
+		for _, name := range types {
+			m.Object("type", p.Types[name])
+		}
+	}
+	if len(funcs) > 0 {
+		m.printf("## Functions\n\n")
+		for _, name := range funcs {
+			m.Func("###", p.Functions[name])
+		}
+	}
+}
+
+func (m *markdown) Object(kind string, ob *collect.Object) {
+	m.printf("### %s %s\n\n", kind, ob.Name)
+	m.doc(ob.Doc, ob.Comment)
+	if ob.Underlying != "" {
+		op := ""
+		if ob.Alias {
+			op = "= "
+		}
+		m.printf("```go\ntype %s %s%s\n```\n\n", ob.Name, op, ob.Underlying)
+	}
+	if ob.Platform != "" {
+		m.printf("platform: `%s`\n\n", ob.Platform)
+	}
+
+	if len(ob.FieldNames) > 0 {
+		header := "| name | type | doc | comment |\n|---|---|---|---|\n"
+		if kind == "interface" {
+			header = "| method | signature | doc | comment |\n|---|---|---|---|\n"
+		}
+		m.printf("%s", header)
+		for _, id := range ob.FieldNames {
+			f := ob.Fields[id]
+			name := cell(f.Name)
+			if f.Embedded {
+				name += " (embedded)"
+			}
+			m.printf("| %s | %s | %s | %s |\n", name, code(f.Type), cell(f.Doc), cell(f.Comment))
+		}
+		m.printf("\n")
+	}
+
+	for _, name := range ob.MethodNames {
+		m.Func("####", ob.Methods[name])
+	}
+
+	for _, id := range ob.FieldNames { // anonymous structs and interfaces
+		if f := ob.Fields[id]; f.Anonymous != nil {
+			m.Object(kind, f.Anonymous)
+		}
+	}
+}
+
+func (m *markdown) Func(heading string, fn *collect.Func) {
+	recv := ""
+	if fn.Recv != "" {
+		m.printf("%s func (%s) %s\n\n", heading, fn.Receiver(), fn.Name)
+		recv = "(" + strings.TrimSpace(fn.RecvName+" "+fn.Receiver()) + ") "
+	} else {
+		m.printf("%s func %s\n\n", heading, fn.Name)
+	}
+	m.printf("```go\nfunc %s%s%s\n```\n\n", recv, fn.Name, declaredSignature(fn))
+	m.doc(fn.Doc, "")
+	if fn.Platform != "" {
+		m.printf("platform: `%s`\n\n", fn.Platform)
+	}
+	m.fields("parameters", fn.ParamNames, fn.Params)
+	m.fields("returns", fn.ReturnNames, fn.Returns)
+}
+
+// declaredSignature returns the signature of the function as declared, with the parameter names (e.g. (x, y int) (n int, err error)).
+func declaredSignature(fn *collect.Func) string {
+	params := "(" + declaredFields(fn.ParamNames, fn.Params) + ")"
+	switch {
+	case len(fn.ReturnNames) == 0:
+		return params
+	case len(fn.ReturnNames) == 1 && fn.Returns[fn.ReturnNames[0]].Name == "":
+		return params + " " + fn.Returns[fn.ReturnNames[0]].Type
+	default:
+		return params + " (" + declaredFields(fn.ReturnNames, fn.Returns) + ")"
+	}
+}
+
+// declaredFields returns the parameters (or results) as declared, the consecutive names of the same type are grouped (e.g. x, y int).
+func declaredFields(names []string, fields map[string]*collect.Field) string {
+	var b strings.Builder
+	for i, id := range names {
+		f := fields[id]
+		if f.Name == "" {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(f.Type)
+			continue
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(f.Name)
+		if next := i + 1; next < len(names) && fields[names[next]].Name != "" && fields[names[next]].Type == f.Type {
+			continue
+		}
+		b.WriteString(" ")
+		b.WriteString(f.Type)
+	}
+	return b.String()
+}
+
+func (m *markdown) fields(title string, names []string, fields map[string]*collect.Field) {
+	if len(names) == 0 {
+		return
+	}
+	m.printf("%s:\n\n| name | type | doc | comment |\n|---|---|---|---|\n", title)
+	for _, id := range names {
+		f := fields[id]
+		m.printf("| %s | %s | %s | %s |\n", cell(f.Name), code(f.Type), cell(f.Doc), cell(f.Comment))
+	}
+	m.printf("\n")
+}
+
+func (m *markdown) doc(doc string, comment string) {
+	if doc = strings.TrimSpace(doc); doc != "" {
+		m.printf("%s\n\n", doc)
+	}
+	if comment = strings.TrimSpace(comment); comment != "" && comment != doc {
+		m.printf("%s\n\n", comment)
+	}
+}
+
+// cell escapes the text for a cell of the table.
+func cell(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
@@ -0,0 +1,361 @@
+package emit
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// OpenAPIFragment is the OpenAPI 3.1 paths of the handlers (functions or methods), and the schemas referenced by them.
+// Both can be merged into the existing spec as is (e.g. .paths *= $f.paths | .components *= $f.components with jq).
+type OpenAPIFragment struct {
+	Paths      map[string]map[string]*Operation `json:"paths"` // path -> method (lowercase, e.g. delete) -> operation
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas,omitempty"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query, header or cookie
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// parameterLocations are the struct tags of the fields of the input struct, to be parameters (e.g. ID int64 `path:"id"`).
+// The other fields are the properties of the request body (application/json).
+var parameterLocations = []string{"path", "query", "header", "cookie"}
+
+// httpMethods are the methods of the route line in the doc of the handler (e.g. DELETE /pets/{id}).
+var httpMethods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"}
+
+// OpenAPI returns the paths of the handlers (the functions and the methods having the route line, if names is empty).
+// The names are the ids of the functions or the methods (e.g. DeletePet, *Server#AddPet, see collect.Package.Lookup).
+// If some of the names are not found, or have no route line, an error is returned.
+//
+// The route of the handler is the line of its doc consisting of the method and the path (e.g. DELETE /pets/{id}).
+// The first line of the rest of the doc is the summary, and the rest is the description.
+// The fields of the input struct become the parameters (tagged with path, query, header or cookie) or the request body,
+// the parameters not of the in-package struct become the query parameters,
+// and the comments of the return values become the descriptions of the responses (error is the default response).
+func OpenAPI(p *collect.Package, names []string) (*OpenAPIFragment, error) {
+	var handlers []*collect.Func
+	if len(names) == 0 {
+		handlers = routedHandlers(p)
+	}
+	var missing []string
+	for _, name := range names {
+		fn, ok := p.Lookup(name).(*collect.Func)
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		if _, _, _, ok := route(fn); !ok {
+			return nil, fmt.Errorf("the route of %s is not found in its doc (e.g. DELETE /pets/{id}): %s", name, Key(p))
+		}
+		handlers = append(handlers, fn)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("the handlers are not found in %s: %s", Key(p), strings.Join(missing, ", "))
+	}
+	return openAPI(p, handlers)
+}
+
+// routedHandlers returns the functions and the methods having the route line, in the source order.
+func routedHandlers(p *collect.Package) []*collect.Func {
+	var handlers []*collect.Func
+	add := func(fn *collect.Func) {
+		if _, _, _, ok := route(fn); ok {
+			handlers = append(handlers, fn)
+		}
+	}
+	for _, name := range p.Names {
+		if fn, ok := p.Functions[name]; ok {
+			add(fn)
+		} else if ob, ok := p.Types[name]; ok {
+			for _, name := range ob.MethodNames {
+				add(ob.Methods[name])
+			}
+		}
+	}
+	return handlers
+}
+
+// route returns the method (lowercase) and the path of the handler, from the route line of its doc, and the doc without the line.
+func route(fn *collect.Func) (method string, path string, doc string, ok bool) {
+	lines := strings.Split(fn.Doc, "\n")
+	for i, line := range lines {
+		m, path, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found || !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " \t") {
+			continue
+		}
+		for _, x := range httpMethods {
+			if m == x {
+				rest := append(append([]string{}, lines[:i]...), lines[i+1:]...)
+				return strings.ToLower(m), path, strings.Join(rest, "\n"), true
+			}
+		}
+	}
+	return "", "", fn.Doc, false
+}
+
+func openAPI(p *collect.Package, handlers []*collect.Func) (*OpenAPIFragment, error) {
+	c := &jsonSchema{p: p, ref: "#/components/schemas/", resolving: map[string]bool{}}
+	r := &OpenAPIFragment{Paths: map[string]map[string]*Operation{}}
+
+	refs := map[string]bool{}
+	for _, fn := range handlers {
+		method, path, _, _ := route(fn)
+		item, ok := r.Paths[path]
+		if !ok {
+			item = map[string]*Operation{}
+			r.Paths[path] = item
+		}
+		if prev, ok := item[method]; ok {
+			return nil, fmt.Errorf("%s %s is handled by both %s and %s: %s", strings.ToUpper(method), path, prev.OperationID, fn.Name, Key(p))
+		}
+		op := c.Operation(fn)
+		item[method] = op
+		walkSchemas(op, func(s *Schema) {
+			if strings.HasPrefix(s.Ref, c.ref) {
+				refs[strings.TrimPrefix(s.Ref, c.ref)] = true
+			}
+		})
+	}
+
+	// the referenced schemas (transitively)
+	r.Components.Schemas = map[string]*Schema{}
+	for len(refs) > 0 {
+		next := map[string]bool{}
+		for name := range refs {
+			if _, ok := r.Components.Schemas[name]; ok {
+				continue
+			}
+			s := c.Object(p.Types[name])
+			r.Components.Schemas[name] = s
+			walkSchema(s, func(s *Schema) {
+				if strings.HasPrefix(s.Ref, c.ref) {
+					next[strings.TrimPrefix(s.Ref, c.ref)] = true
+				}
+			})
+		}
+		refs = next
+	}
+	return r, nil
+}
+
+func (c *jsonSchema) Operation(fn *collect.Func) *Operation {
+	op := &Operation{OperationID: fn.Name, Responses: map[string]*Response{}}
+	_, _, doc, _ := route(fn)
+	doc = strings.TrimSpace(doc)
+	if i := strings.Index(doc, "\n"); i >= 0 {
+		op.Summary, op.Description = doc[:i], strings.TrimSpace(doc[i+1:])
+	} else {
+		op.Summary = doc
+	}
+
+	body := &Schema{Type: "object", Properties: &Properties{Schemas: map[string]*Schema{}}}
+	for _, id := range fn.ParamNames {
+		f := fn.Params[id]
+		if f.Type == "context.Context" {
+			continue
+		}
+		if ob := lookupStruct(c.p, f.Type); ob != nil {
+			c.input(op, body, ob, map[string]bool{ob.Name: true})
+			continue
+		}
+		param := &Parameter{Name: f.Name, In: "query", Description: description(f.Doc, f.Comment), Required: !strings.HasPrefix(f.Type, "*")}
+		if expr, err := parser.ParseExpr(f.Type); err == nil {
+			param.Schema = c.Expr(expr)
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+	if len(body.Properties.Names) > 0 {
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{"application/json": {Schema: body}}}
+	}
+
+	for _, id := range fn.ReturnNames {
+		f := fn.Returns[id]
+		desc := description(f.Doc, f.Comment)
+		if f.Type == "error" {
+			if desc == "" {
+				desc = "error"
+			}
+			op.Responses["default"] = &Response{Description: desc}
+			continue
+		}
+		if desc == "" {
+			desc = "OK"
+		}
+		res := &Response{Description: desc}
+		if f.Type != "struct{}" {
+			if expr, err := parser.ParseExpr(f.Type); err == nil {
+				if s := c.Expr(expr); s != nil {
+					res.Content = map[string]*MediaType{"application/json": {Schema: s}}
+				}
+			}
+		}
+		op.Responses["200"] = res
+	}
+	if _, ok := op.Responses["200"]; !ok {
+		op.Responses["204"] = &Response{Description: "No Content"}
+	}
+	return op
+}
+
+// input sets the parameters and the request body from the fields of the input struct (the embedded structs are flattened).
+func (c *jsonSchema) input(op *Operation, body *Schema, ob *collect.Object, seen map[string]bool) {
+	bodyFields := &collect.Object{Name: ob.Name, Fields: map[string]*collect.Field{}}
+	for _, id := range ob.FieldNames {
+		f := ob.Fields[id]
+		in, name, opts := "", "", ""
+		for _, loc := range parameterLocations {
+			if v, ok := reflect.StructTag(f.Tag).Lookup(loc); ok {
+				in, name = loc, v
+				if i := strings.Index(v, ","); i >= 0 {
+					name, opts = v[:i], v[i+1:]
+				}
+				break
+			}
+		}
+		if in == "" {
+			if target := lookupStruct(c.p, f.Type); f.Embedded && target != nil && f.Tag == "" && !seen[target.Name] {
+				seen[target.Name] = true
+				c.input(op, body, target, seen)
+				continue
+			}
+			bodyFields.FieldNames = append(bodyFields.FieldNames, id)
+			bodyFields.Fields[id] = f
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		param := &Parameter{
+			Name:        name,
+			In:          in,
+			Description: description(f.Doc, f.Comment),
+			Required:    in == "path" || !strings.Contains(","+opts+",", ",omitempty,"),
+		}
+		if expr, err := parser.ParseExpr(f.Type); err == nil {
+			param.Schema = c.Expr(expr)
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+
+	if len(bodyFields.FieldNames) > 0 {
+		c.fields(body, bodyFields, seen)
+	}
+}
+
+// walkSchemas calls visit with the schemas of the operation.
+func walkSchemas(op *Operation, visit func(*Schema)) {
+	for _, param := range op.Parameters {
+		walkSchema(param.Schema, visit)
+	}
+	if op.RequestBody != nil {
+		for _, m := range op.RequestBody.Content {
+			walkSchema(m.Schema, visit)
+		}
+	}
+	for _, res := range op.Responses {
+		for _, m := range res.Content {
+			walkSchema(m.Schema, visit)
+		}
+	}
+}
+
+func walkSchema(s *Schema, visit func(*Schema)) {
+	if s == nil {
+		return
+	}
+	visit(s)
+	walkSchema(s.Items, visit)
+	walkSchema(s.AdditionalProperties, visit)
+	if s.Properties != nil {
+		for _, name := range s.Properties.Names {
+			walkSchema(s.Properties.Schemas[name], visit)
+		}
+	}
+	for _, def := range s.Defs {
+		walkSchema(def, visit)
+	}
+}
+
+// NewOpenAPI returns the emitter writing the paths of the handlers per package (the functions and the methods having the route line, if names is empty, see OpenAPI).
+// The names are looked up in each package, the packages having none of them are skipped,
+// and Close returns an error if some of them are not found in any package.
+func NewOpenAPI(w io.Writer, names []string) Emitter {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "	")
+	return &openAPIEmitter{enc: enc, names: names, found: map[string]bool{}}
+}
+
+type openAPIEmitter struct {
+	enc   *json.Encoder
+	names []string
+	found map[string]bool
+}
+
+func (e *openAPIEmitter) Emit(p *collect.Package) error {
+	var names []string
+	for _, name := range e.names {
+		if _, ok := p.Lookup(name).(*collect.Func); ok {
+			names = append(names, name)
+			e.found[name] = true
+		}
+	}
+	if len(names) == 0 && (len(e.names) > 0 || len(routedHandlers(p)) == 0) {
+		return nil // no handlers
+	}
+
+	r, err := OpenAPI(p, names)
+	if err != nil {
+		return err
+	}
+	return e.enc.Encode(r)
+}
+
+func (e *openAPIEmitter) Close() error {
+	var missing []string
+	for _, name := range e.names {
+		if !e.found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("openapi: the handlers are not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
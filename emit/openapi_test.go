@@ -0,0 +1,202 @@
+package emit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/podhmo/commentof"
+	"github.com/podhmo/commentof/collect"
+)
+
+func source(t *testing.T, src string) *collect.Package {
+	t.Helper()
+	p, err := commentof.Source("x.go", []byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	return p
+}
+
+func TestRoute(t *testing.T) {
+	cases := []struct {
+		name       string
+		doc        string
+		wantMethod string
+		wantPath   string
+		wantDoc    string
+	}{
+		{name: "route line", doc: "Deletes a pet\n\nDELETE /pets/{id}\n\ndetails\n", wantMethod: "delete", wantPath: "/pets/{id}", wantDoc: "Deletes a pet\n\n\ndetails\n"},
+		{name: "indented", doc: "List pets\n  GET /pets\n", wantMethod: "get", wantPath: "/pets", wantDoc: "List pets\n"},
+		{name: "lowercase method", doc: "get /pets\n", wantDoc: "get /pets\n"},
+		{name: "not a path", doc: "GET the pets\n", wantDoc: "GET the pets\n"},
+		{name: "trailing words", doc: "POST /pets and more\n", wantDoc: "POST /pets and more\n"},
+		{name: "unknown method", doc: "FETCH /pets\n", wantDoc: "FETCH /pets\n"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			method, path, doc, ok := route(&collect.Func{Doc: c.doc})
+			if ok != (c.wantMethod != "") {
+				t.Fatalf("found: want %v, but got %v", c.wantMethod != "", ok)
+			}
+			if method != c.wantMethod || path != c.wantPath || doc != c.wantDoc {
+				t.Errorf("want (%q, %q, %q), but got (%q, %q, %q)", c.wantMethod, c.wantPath, c.wantDoc, method, path, doc)
+			}
+		})
+	}
+}
+
+const handlers = `package handler
+
+// Pet is a pet.
+type Pet struct {
+	Name string ` + "`json:\"name\"`" + ` // name of the pet
+}
+
+// Server is the server.
+type Server struct{}
+
+// List pets
+//
+// GET /pets
+func (s *Server) ListPets() ([]*Pet, error) { return nil, nil }
+
+// Count pets (no route)
+func (s *Server) Count() int { return 0 }
+
+// Find a pet
+//
+// GET /pets/{id}
+func FindPet(id int64) (*Pet, error) { return nil, nil }
+
+// NewPet returns a pet (no route).
+func NewPet(name string) *Pet { return nil }
+`
+
+func routes(r *OpenAPIFragment) []string {
+	var routes []string
+	for path, item := range r.Paths {
+		for method, op := range item {
+			routes = append(routes, method+" "+path+" "+op.OperationID)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+func TestOpenAPI(t *testing.T) {
+	p := source(t, handlers)
+
+	cases := []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr string
+	}{
+		{name: "all", want: []string{"get /pets ListPets", "get /pets/{id} FindPet"}},
+		{name: "function", names: []string{"FindPet"}, want: []string{"get /pets/{id} FindPet"}},
+		{name: "method", names: []string{"*Server#ListPets"}, want: []string{"get /pets ListPets"}},
+		{name: "method without *", names: []string{"Server#ListPets"}, want: []string{"get /pets ListPets"}},
+		{name: "no route", names: []string{"NewPet"}, wantErr: "the route of NewPet is not found"},
+		{name: "method without route", names: []string{"*Server#Count"}, wantErr: "the route of *Server#Count is not found"},
+		{name: "not found", names: []string{"FindPet", "DeletePet", "Pet"}, wantErr: "the handlers are not found in .: DeletePet, Pet"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			r, err := OpenAPI(p, c.names)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("want the error %q, but got %+v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if got := routes(r); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want %q, but got %q", c.want, got)
+			}
+			if _, ok := r.Components.Schemas["Pet"]; !ok {
+				t.Errorf("the referenced schema is not found: %v", r.Components.Schemas)
+			}
+		})
+	}
+}
+
+func TestOpenAPIConflict(t *testing.T) {
+	p := source(t, `package handler
+
+// GET /pets
+func ListPets() {}
+
+// GET /pets
+func AllPets() {}
+`)
+	_, err := OpenAPI(p, nil)
+	if err == nil || !strings.Contains(err.Error(), "GET /pets is handled by both ListPets and AllPets") {
+		t.Errorf("want the conflict error, but got %+v", err)
+	}
+}
+
+func TestOpenAPIEmitter(t *testing.T) {
+	withHandlers := source(t, handlers)
+	withoutHandlers := source(t, "package util\n\n// F is not a handler.\nfunc F() {}\n")
+
+	cases := []struct {
+		name        string
+		names       []string
+		want        [][]string // the routes of each document
+		wantEmitErr string
+		wantErr     string // the error of Close
+	}{
+		{name: "all", want: [][]string{{"get /pets ListPets", "get /pets/{id} FindPet"}}},
+		{name: "named", names: []string{"*Server#ListPets"}, want: [][]string{{"get /pets ListPets"}}},
+		{name: "named without route", names: []string{"F"}, wantEmitErr: "the route of F is not found"},
+		{name: "not found", names: []string{"FindPet", "DeletePet"}, want: [][]string{{"get /pets/{id} FindPet"}}, wantErr: "openapi: the handlers are not found: DeletePet"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewOpenAPI(&buf, c.names)
+			var emitErr error
+			for _, p := range []*collect.Package{withoutHandlers, withHandlers} {
+				if err := e.Emit(p); err != nil {
+					emitErr = err
+				}
+			}
+			if c.wantEmitErr == "" && emitErr != nil {
+				t.Fatalf("unexpected error: %+v", emitErr)
+			}
+			if c.wantEmitErr != "" && (emitErr == nil || !strings.Contains(emitErr.Error(), c.wantEmitErr)) {
+				t.Errorf("want the error %q, but got %+v", c.wantEmitErr, emitErr)
+			}
+
+			err := e.Close()
+			if c.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %+v", err)
+			}
+			if c.wantErr != "" && (err == nil || err.Error() != c.wantErr) {
+				t.Errorf("want the error %q, but got %+v", c.wantErr, err)
+			}
+
+			var got [][]string
+			dec := json.NewDecoder(&buf)
+			for dec.More() {
+				var r OpenAPIFragment
+				if err := dec.Decode(&r); err != nil {
+					t.Fatalf("unexpected error: %+v", err)
+				}
+				got = append(got, routes(&r))
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want %q, but got %q (the packages without the handlers are skipped)", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package emit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// Proto writes the .proto file (proto3) of the package, with the docs and the comments as the leading comments.
+//
+// The exported structs become messages (the embedded structs are flattened, and the anonymous structs are nested messages),
+// and the exported interfaces become services (the methods of the form func([context.Context,] Req) (Res[, error]) are rpcs).
+// The slices are repeated fields, and the types not supported are skipped with a comment (see TypeMap to map them), reserving their numbers.
+//
+// The field numbers are taken from the protobuf tags (e.g. protobuf:"varint,3,opt,name=id", as protoc-gen-go writes).
+// The other fields are numbered in the order of the fields, so their numbers are not stable
+// (inserting or removing a field renumbers the later ones, which breaks the wire compatibility).
+func Proto(w io.Writer, p *collect.Package, typemap *TypeMap) error {
+	if typemap == nil {
+		typemap = &TypeMap{}
+	}
+	g := &proto{p: p, typemap: typemap, imports: map[string]bool{}}
+	for _, name := range typemap.ProtoImports {
+		g.imports[name] = true
+	}
+
+	var body bytes.Buffer
+	g.w = bufio.NewWriter(&body)
+	for _, name := range p.Names {
+		if ob, ok := p.Types[name]; ok && isStruct(ob) && ast.IsExported(name) {
+			g.printf("\n")
+			g.Message("", ob.Name, ob)
+		} else if ob, ok := p.Interfaces[name]; ok && ast.IsExported(name) {
+			g.printf("\n")
+			g.Service(ob)
+		}
+	}
+	if err := g.w.Flush(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "// package %s", p.Name)
+	if p.ImportPath != "" {
+		fmt.Fprintf(bw, " (%s)", p.ImportPath)
+	}
+	fmt.Fprintf(bw, "\nsyntax = \"proto3\";\n\npackage %s;\n", p.Name)
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+		for name := range g.imports {
+			imports = append(imports, name)
+		}
+		sort.Strings(imports)
+		bw.WriteString("\n")
+		for _, name := range imports {
+			fmt.Fprintf(bw, "import %q;\n", name)
+		}
+	}
+	if p.ImportPath != "" {
+		fmt.Fprintf(bw, "\noption go_package = %q;\n", p.ImportPath)
+	}
+	bw.Write(body.Bytes())
+	return bw.Flush()
+}
+
+type protoEmitter struct {
+	w       io.Writer
+	typemap *TypeMap
+	count   int
+}
+
+// NewProto returns the emitter writing the .proto file per package (see Proto).
+func NewProto(w io.Writer, typemap *TypeMap) Emitter {
+	return &protoEmitter{w: w, typemap: typemap}
+}
+
+func (e *protoEmitter) Emit(p *collect.Package) error {
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, "\n"); err != nil {
+			return err
+		}
+	}
+	e.count++
+	return Proto(e.w, p, e.typemap)
+}
+
+func (e *protoEmitter) Close() error { return nil }
+
+// wellKnownTypes are the imports of the well-known types.
+var wellKnownTypes = map[string]string{
+	"google.protobuf.Timestamp": "google/protobuf/timestamp.proto",
+	"google.protobuf.Duration":  "google/protobuf/duration.proto",
+	"google.protobuf.Value":     "google/protobuf/struct.proto",
+	"google.protobuf.Struct":    "google/protobuf/struct.proto",
+	"google.protobuf.Any":       "google/protobuf/any.proto",
+	"google.protobuf.Empty":     "google/protobuf/empty.proto",
+}
+
+type proto struct {
+	w       *bufio.Writer
+	p       *collect.Package
+	typemap *TypeMap
+	imports map[string]bool
+
+	resolving map[string]bool
+}
+
+func (g *proto) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.w, format, args...)
+}
+
+func (g *proto) Message(indent string, name string, ob *collect.Object) {
+	g.comment(indent, description(ob.Doc, ob.Comment))
+	g.printf("%smessage %s {\n", indent, name)
+	var nested []*collect.Object
+	var nestedNames []string
+	fields := jsonFields(g.p, ob)
+	numbers := fieldNumbers(fields)
+	for i, f := range fields {
+		name := snakeCase(f.JSONName)
+		doc := description(f.Doc, f.Comment)
+		var typ protoType
+		if f.Anonymous != nil && isStruct(f.Anonymous) {
+			typ = protoType{Name: upperCamelCase(name), OK: true}
+			nested = append(nested, f.Anonymous)
+			nestedNames = append(nestedNames, typ.Name)
+		} else if expr, err := parser.ParseExpr(f.Type); err == nil {
+			typ = g.Type(expr)
+		}
+		if !typ.OK {
+			g.printf("%s\t// %s: unsupported type %s\n", indent, name, f.Type)
+			g.printf("%s\treserved %d;\n", indent, numbers[i])
+			continue
+		}
+
+		g.comment(indent+"\t", doc)
+		label := ""
+		if typ.Repeated {
+			label = "repeated "
+		} else if typ.Optional {
+			label = "optional "
+		}
+		g.printf("%s\t%s%s %s = %d;\n", indent, label, typ.Name, name, numbers[i])
+	}
+	for i, ob := range nested {
+		g.printf("\n")
+		g.Message(indent+"\t", nestedNames[i], ob)
+	}
+	g.printf("%s}\n", indent)
+}
+
+// fieldNumbers returns the field numbers of the fields.
+// The number in the protobuf tag is used if it exists (e.g. protobuf:"varint,3,opt,name=id"),
+// and the others are numbered in the order of the fields, skipping the numbers in the tags.
+func fieldNumbers(fields []*jsonField) []int {
+	numbers := make([]int, len(fields))
+	used := map[int]bool{}
+	for i, f := range fields {
+		for _, v := range strings.Split(reflect.StructTag(f.Tag).Get("protobuf"), ",") {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				numbers[i] = n
+				used[n] = true
+				break
+			}
+		}
+	}
+	n := 0
+	for i := range fields {
+		if numbers[i] != 0 {
+			continue
+		}
+		for n++; used[n]; n++ {
+		}
+		numbers[i] = n
+	}
+	return numbers
+}
+
+func (g *proto) Service(ob *collect.Object) {
+	g.comment("", description(ob.Doc, ob.Comment))
+	g.printf("service %s {\n", ob.Name)
+	for _, id := range ob.FieldNames {
+		f := ob.Fields[id]
+		if f.Embedded || f.Anonymous != nil {
+			continue
+		}
+		expr, err := parser.ParseExpr(f.Type)
+		if err != nil {
+			continue
+		}
+		fn, ok := expr.(*ast.FuncType)
+		if !ok {
+			continue
+		}
+		req, res := g.rpcType(fn.Params), g.rpcType(fn.Results)
+		if req == "" || res == "" {
+			g.printf("\t// %s: unsupported signature %s\n", f.Name, f.Type)
+			continue
+		}
+		g.comment("\t", description(f.Doc, f.Comment))
+		g.printf("\trpc %s(%s) returns (%s);\n", f.Name, req, res)
+	}
+	g.printf("}\n")
+}
+
+// rpcType returns the message of the request or the response (context.Context and error are ignored).
+func (g *proto) rpcType(fields *ast.FieldList) string {
+	var exprs []ast.Expr
+	if fields != nil {
+		for _, f := range fields.List {
+			switch types.ExprString(f.Type) {
+			case "context.Context", "error":
+				continue
+			}
+			n := len(f.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				exprs = append(exprs, f.Type)
+			}
+		}
+	}
+	if len(exprs) == 0 {
+		g.imports[wellKnownTypes["google.protobuf.Empty"]] = true
+		return "google.protobuf.Empty"
+	}
+	if len(exprs) > 1 {
+		return ""
+	}
+	typ := g.Type(exprs[0])
+	if !typ.OK || typ.Repeated || typ.Scalar {
+		return ""
+	}
+	return typ.Name
+}
+
+type protoType struct {
+	Name     string
+	Repeated bool
+	Optional bool // pointer to the scalar
+	Scalar   bool
+	OK       bool
+}
+
+// Type returns the proto type of the type expression.
+func (g *proto) Type(expr ast.Expr) protoType {
+	if name, ok := g.typemap.Proto[types.ExprString(expr)]; ok {
+		return g.named(name)
+	}
+	switch x := expr.(type) {
+	case *ast.Ident:
+		switch x.Name {
+		case "bool", "string", "int32", "int64", "uint32", "uint64":
+			return protoType{Name: x.Name, Scalar: true, OK: true}
+		case "int":
+			return protoType{Name: "int64", Scalar: true, OK: true}
+		case "int8", "int16", "rune":
+			return protoType{Name: "int32", Scalar: true, OK: true}
+		case "uint", "uintptr":
+			return protoType{Name: "uint64", Scalar: true, OK: true}
+		case "uint8", "uint16", "byte":
+			return protoType{Name: "uint32", Scalar: true, OK: true}
+		case "float32":
+			return protoType{Name: "float", Scalar: true, OK: true}
+		case "float64":
+			return protoType{Name: "double", Scalar: true, OK: true}
+		case "any":
+			return g.named("google.protobuf.Value")
+		}
+		ob, ok := g.p.Types[x.Name]
+		if !ok {
+			return protoType{}
+		}
+		if isStruct(ob) {
+			return protoType{Name: x.Name, OK: true}
+		}
+		if ob.Underlying == "" || g.resolving[x.Name] {
+			return protoType{}
+		}
+		if g.resolving == nil {
+			g.resolving = map[string]bool{}
+		}
+		g.resolving[x.Name] = true
+		defer delete(g.resolving, x.Name)
+		underlying, err := parser.ParseExpr(ob.Underlying)
+		if err != nil {
+			return protoType{}
+		}
+		return g.Type(underlying) // defined types are not in proto
+	case *ast.StarExpr:
+		typ := g.Type(x.X)
+		if typ.Scalar && !typ.Repeated {
+			typ.Optional = true
+		}
+		return typ
+	case *ast.ParenExpr:
+		return g.Type(x.X)
+	case *ast.ArrayType:
+		if ident, ok := x.Elt.(*ast.Ident); ok && ident.Name == "byte" && x.Len == nil {
+			return protoType{Name: "bytes", Scalar: true, OK: true}
+		}
+		typ := g.Type(x.Elt)
+		if !typ.OK || typ.Repeated || strings.HasPrefix(typ.Name, "map<") {
+			return protoType{}
+		}
+		typ.Repeated = true
+		typ.Optional = false
+		return typ
+	case *ast.MapType:
+		key, value := g.Type(x.Key), g.Type(x.Value)
+		if !key.OK || !key.Scalar || key.Name == "float" || key.Name == "double" || key.Name == "bytes" {
+			return protoType{}
+		}
+		if !value.OK || value.Repeated || strings.HasPrefix(value.Name, "map<") {
+			return protoType{}
+		}
+		return protoType{Name: fmt.Sprintf("map<%s, %s>", key.Name, value.Name), OK: true}
+	case *ast.SelectorExpr:
+		switch exprString(x) {
+		case "time.Time":
+			return g.named("google.protobuf.Timestamp")
+		case "time.Duration":
+			return g.named("google.protobuf.Duration")
+		}
+		return protoType{}
+	case *ast.InterfaceType:
+		return g.named("google.protobuf.Value")
+	default:
+		return protoType{}
+	}
+}
+
+func (g *proto) named(name string) protoType {
+	if path, ok := wellKnownTypes[name]; ok {
+		g.imports[path] = true
+	}
+	switch name {
+	case "bool", "string", "bytes", "int32", "int64", "uint32", "uint64", "sint32", "sint64", "fixed32", "fixed64", "sfixed32", "sfixed64", "float", "double":
+		return protoType{Name: name, Scalar: true, OK: true}
+	}
+	return protoType{Name: name, OK: true}
+}
+
+func (g *proto) comment(indent string, doc string) {
+	if doc == "" {
+		return
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		g.printf("%s%s\n", indent, strings.TrimRight("// "+line, " "))
+	}
+}
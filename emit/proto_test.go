@@ -0,0 +1,85 @@
+package emit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProtoMapping(t *testing.T) {
+	const src = `package api
+
+import (
+	"context"
+	"time"
+)
+
+// Item is an item.
+type Item struct {
+	ID      int64     ` + "`json:\"id\" protobuf:\"varint,3,opt,name=id\"`" + `
+	Note    *string   ` + "`json:\"note\"`" + `
+	Tags    []string  ` + "`json:\"tags\"`" + `
+	Ch      chan int  ` + "`json:\"ch\"`" + `
+	Created time.Time ` + "`json:\"created\"`" + `
+}
+
+// Service is the service.
+type Service interface {
+	Get(ctx context.Context, id int64) (*Item, error)
+	Create(ctx context.Context, item *Item) (*Item, error)
+	Ping(ctx context.Context) (*Item, error)
+	List(ctx context.Context, item Item) ([]*Item, error)
+	Between(from, to Item) (*Item, error)
+}
+`
+	p := source(t, src)
+
+	cases := []struct {
+		name     string
+		typemap  *TypeMap
+		wants    []string
+		notWants []string
+	}{
+		{name: "the number of the protobuf tag", wants: []string{"\tint64 id = 3;\n"}},
+		{name: "pointer to the scalar is optional", wants: []string{"\toptional string note = "}},
+		{name: "slice is repeated", wants: []string{"\trepeated string tags = "}},
+		{name: "unsupported field is skipped", wants: []string{"\t// ch: unsupported type chan int\n", "\treserved "}},
+		{name: "time", wants: []string{"\tgoogle.protobuf.Timestamp created = ", `import "google/protobuf/timestamp.proto";`}},
+		{name: "scalar request", wants: []string{"\t// Get: unsupported signature func(context.Context, int64) (*Item, error)\n"}},
+		{name: "message request", wants: []string{"\trpc Create(Item) returns (Item);\n"}},
+		{name: "no request", wants: []string{"\trpc Ping(google.protobuf.Empty) returns (Item);\n", `import "google/protobuf/empty.proto";`}},
+		{name: "repeated response", wants: []string{"\t// List: unsupported signature func(context.Context, Item) ([]*Item, error)\n"}},
+		{name: "multiple requests", wants: []string{"\t// Between: unsupported signature func(Item, Item) (*Item, error)\n"}},
+		{
+			name:     "mapped type",
+			typemap:  &TypeMap{Proto: map[string]string{"time.Time": "int64"}},
+			wants:    []string{"\tint64 created = "},
+			notWants: []string{"timestamp.proto"},
+		},
+		{
+			name:    "mapped message with the import",
+			typemap: &TypeMap{Proto: map[string]string{"time.Time": "mycompany.Time"}, ProtoImports: []string{"mycompany/time.proto"}},
+			wants:   []string{"\tmycompany.Time created = ", `import "mycompany/time.proto";`},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Proto(&buf, p, c.typemap); err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			got := buf.String()
+			for _, s := range c.wants {
+				if !strings.Contains(got, s) {
+					t.Errorf("want %q in\n%s", s, got)
+				}
+			}
+			for _, s := range c.notWants {
+				if strings.Contains(got, s) {
+					t.Errorf("unexpected %q in\n%s", s, got)
+				}
+			}
+		})
+	}
+}
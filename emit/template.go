@@ -0,0 +1,182 @@
+package emit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// FuncMap returns the helper functions for the templates.
+//
+//   - firstSentence: the first sentence of the doc
+//   - indent: indent each line (e.g. {{ indent 4 .Doc }})
+//   - wrap: wrap the text at the width (e.g. {{ wrap 80 .Doc }})
+//   - fields, methods, params, returns: the fields, methods, parameters and return values in the source order
+//   - symbols: the top-level symbols in the source order (see Symbol)
+//   - lookup: the symbol by the id (see collect.Package.Lookup)
+//   - markdown, cell: escape the text for markdown (cell is for a cell of the table)
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"firstSentence": firstSentence,
+		"indent":        indent,
+		"wrap":          wrap,
+		"fields":        fields,
+		"methods":       methods,
+		"params":        params,
+		"returns":       returns,
+		"symbols":       symbols,
+		"lookup":        func(p *collect.Package, id string) interface{} { return p.Lookup(id) },
+		"markdown":      escapeMarkdown,
+		"cell":          cell,
+		"trim":          strings.TrimSpace,
+	}
+}
+
+// NewTemplate returns the emitter rendering each package with the template file (text/template, see FuncMap).
+func NewTemplate(w io.Writer, filename string) (Emitter, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(filename)).Funcs(FuncMap()).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return &templateEmitter{w: w, tmpl: tmpl}, nil
+}
+
+type templateEmitter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (e *templateEmitter) Emit(p *collect.Package) error {
+	if err := e.tmpl.Execute(e.w, p); err != nil {
+		return fmt.Errorf("execute template: %w", err)
+	}
+	return nil
+}
+
+func (e *templateEmitter) Close() error { return nil }
+
+func firstSentence(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "\n\n"); i >= 0 {
+		s = s[:i]
+	}
+	for i, r := range s {
+		if r == '.' && (i+1 == len(s) || unicode.IsSpace(rune(s[i+1]))) {
+			s = s[:i+1]
+			break
+		}
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func indent(n int, s string) string {
+	prefix := strings.Repeat(" ", n)
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrap wraps the text at the width. The paragraphs (separated by blank lines) are kept.
+func wrap(width int, s string) string {
+	paragraphs := strings.Split(strings.TrimSpace(s), "\n\n")
+	for i, paragraph := range paragraphs {
+		var lines []string
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		paragraphs[i] = strings.Join(lines, "\n")
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func fields(ob *collect.Object) []*collect.Field {
+	r := make([]*collect.Field, 0, len(ob.FieldNames))
+	for _, id := range ob.FieldNames {
+		r = append(r, ob.Fields[id])
+	}
+	return r
+}
+
+func methods(ob *collect.Object) []*collect.Func {
+	r := make([]*collect.Func, 0, len(ob.MethodNames))
+	for _, name := range ob.MethodNames {
+		r = append(r, ob.Methods[name])
+	}
+	return r
+}
+
+func params(fn *collect.Func) []*collect.Field {
+	r := make([]*collect.Field, 0, len(fn.ParamNames))
+	for _, id := range fn.ParamNames {
+		r = append(r, fn.Params[id])
+	}
+	return r
+}
+
+func returns(fn *collect.Func) []*collect.Field {
+	r := make([]*collect.Field, 0, len(fn.ReturnNames))
+	for _, id := range fn.ReturnNames {
+		r = append(r, fn.Returns[id])
+	}
+	return r
+}
+
+// Symbol is a top-level symbol of the package (one of Object, Func and Const is set).
+type Symbol struct {
+	ID   string
+	Kind string // type, interface, func or const
+
+	Object *collect.Object
+	Func   *collect.Func
+	Const  *collect.Const
+}
+
+func symbols(p *collect.Package) []*Symbol {
+	r := make([]*Symbol, 0, len(p.Names))
+	for _, id := range p.Names {
+		if ob, ok := p.Types[id]; ok {
+			r = append(r, &Symbol{ID: id, Kind: "type", Object: ob})
+		} else if ob, ok := p.Interfaces[id]; ok {
+			r = append(r, &Symbol{ID: id, Kind: "interface", Object: ob})
+		} else if fn, ok := p.Functions[id]; ok {
+			r = append(r, &Symbol{ID: id, Kind: "func", Func: fn})
+		} else if c, ok := p.Constants[id]; ok {
+			r = append(r, &Symbol{ID: id, Kind: "const", Const: c})
+		}
+	}
+	return r
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", "&lt;", ">", "&gt;", "#", `\#`, "|", `\|`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
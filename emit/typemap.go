@@ -0,0 +1,153 @@
+package emit
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// TypeMap is the mapping from the Go types (type expressions, e.g. time.Time, uuid.UUID) to the types of the proto and graphql formats.
+// It is loaded from the JSON file, e.g.
+//
+//	{
+//	  "proto": {"uuid.UUID": "string", "time.Time": "int64"},
+//	  "protoImports": ["mycompany/types.proto"],
+//	  "graphql": {"uuid.UUID": "ID", "decimal.Decimal": "Float"}
+//	}
+type TypeMap struct {
+	Proto        map[string]string `json:"proto,omitempty"`
+	ProtoImports []string          `json:"protoImports,omitempty"` // the imports for the mapped proto types
+	GraphQL      map[string]string `json:"graphql,omitempty"`
+}
+
+// LoadTypeMap loads the type mapping from the JSON file.
+func LoadTypeMap(filename string) (*TypeMap, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m TypeMap
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("load typemap: %s, %w", filename, err)
+	}
+	return &m, nil
+}
+
+// jsonField is the field of the struct encoded as JSON.
+type jsonField struct {
+	*collect.Field
+	JSONName string // the name in the json tag, or the field name
+	Opts     string // the options of the json tag (e.g. omitempty)
+}
+
+func (f *jsonField) hasOpt(opt string) bool {
+	return strings.Contains(","+f.Opts+",", ","+opt+",")
+}
+
+// jsonFields returns the fields of the struct, in the manner of encoding/json (the embedded structs are flattened, and the outer fields win).
+func jsonFields(p *collect.Package, ob *collect.Object) []*jsonField {
+	var r []*jsonField
+	seen := map[string]bool{}
+	var walk func(ob *collect.Object, visited map[string]bool)
+	walk = func(ob *collect.Object, visited map[string]bool) {
+		var embedded []*collect.Object
+		for _, id := range ob.FieldNames {
+			f := ob.Fields[id]
+			tag := reflect.StructTag(f.Tag).Get("json")
+			name, opts := tag, ""
+			if i := strings.Index(tag, ","); i >= 0 {
+				name, opts = tag[:i], tag[i+1:]
+			}
+			if name == "-" && opts == "" {
+				continue
+			}
+			if f.Embedded && name == "" {
+				if target := lookupStruct(p, f.Type); target != nil && !visited[target.Name] {
+					embedded = append(embedded, target)
+				}
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if name == "" || !token.IsExported(f.Name) || seen[name] {
+				continue
+			}
+			seen[name] = true
+			r = append(r, &jsonField{Field: f, JSONName: name, Opts: opts})
+		}
+		for _, target := range embedded {
+			visited[target.Name] = true
+			walk(target, visited)
+		}
+	}
+	walk(ob, map[string]bool{ob.Name: true})
+	return r
+}
+
+// isStruct reports whether the object is the struct (not the defined type, alias or interface).
+func isStruct(ob *collect.Object) bool {
+	return ob.Underlying == "" && ob.Token != token.INTERFACE
+}
+
+// snakeCase converts the name to snake_case (e.g. APIKey -> api_key).
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		upper := 'A' <= r && r <= 'Z'
+		if upper && i > 0 && runes[i-1] != '_' {
+			prevLower := 'a' <= runes[i-1] && runes[i-1] <= 'z' || '0' <= runes[i-1] && runes[i-1] <= '9'
+			nextLower := i+1 < len(runes) && 'a' <= runes[i+1] && runes[i+1] <= 'z'
+			if prevLower || nextLower && 'A' <= runes[i-1] && runes[i-1] <= 'Z' {
+				b.WriteByte('_')
+			}
+		}
+		if upper {
+			r += 'a' - 'A'
+		}
+		if r == '-' || r == '.' || r == ' ' {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// lowerCamelCase converts the name to lowerCamelCase (e.g. APIKey -> apiKey, Name -> name).
+func lowerCamelCase(name string) string {
+	runes := []rune(name)
+	for i, r := range runes {
+		if !('A' <= r && r <= 'Z') {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && 'a' <= runes[i+1] && runes[i+1] <= 'z' {
+			break // the first letter of the next word (e.g. the K of APIKey)
+		}
+		runes[i] = r + 'a' - 'A'
+	}
+	return string(runes)
+}
+
+// upperCamelCase converts the name to UpperCamelCase (e.g. api_key -> ApiKey).
+func upperCamelCase(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if r == '_' || r == '-' || r == '.' || r == ' ' {
+			upper = true
+			continue
+		}
+		if upper && 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
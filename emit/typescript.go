@@ -0,0 +1,312 @@
+package emit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// TypeScript writes the TypeScript declarations of the named types of the package, with the docs and the comments as JSDoc.
+//
+// The structs become interfaces (the property names follow the json tags, and the embedded structs are extended),
+// the other types become type aliases, and the typed constants of a defined type become its union type.
+func TypeScript(w io.Writer, p *collect.Package) error {
+	bw := bufio.NewWriter(w)
+	t := &typescript{w: bw, p: p}
+	t.Package()
+	return bw.Flush()
+}
+
+type typescriptEmitter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *typescriptEmitter) Emit(p *collect.Package) error {
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, "\n"); err != nil {
+			return err
+		}
+	}
+	e.count++
+	return TypeScript(e.w, p)
+}
+
+func (e *typescriptEmitter) Close() error { return nil }
+
+type typescript struct {
+	w *bufio.Writer
+	p *collect.Package
+}
+
+func (t *typescript) printf(format string, args ...interface{}) {
+	fmt.Fprintf(t.w, format, args...)
+}
+
+func (t *typescript) Package() {
+	t.printf("// package %s", t.p.Name)
+	if t.p.ImportPath != "" {
+		t.printf(" (%s)", t.p.ImportPath)
+	}
+	t.printf("\n")
+
+	for _, name := range t.p.Names {
+		ob, ok := t.p.Types[name]
+		if !ok {
+			continue
+		}
+		if ob.Underlying == "" { // struct
+			t.printf("\n")
+			t.jsdoc("", description(ob.Doc, ob.Comment))
+			t.printf("export interface %s", ob.Name)
+			extends := t.extends(ob)
+			if len(extends) > 0 {
+				t.printf(" extends %s", strings.Join(extends, ", "))
+			}
+			t.printf(" ")
+			t.fields("", ob)
+			t.printf("\n")
+			continue
+		}
+
+		expr, err := parser.ParseExpr(ob.Underlying)
+		if err != nil {
+			continue
+		}
+		typ, ok := t.Expr(expr, "")
+		if !ok {
+			continue // e.g. func, chan
+		}
+		doc := description(ob.Doc, ob.Comment)
+		if !ob.Alias {
+			if consts, values := enum(t.p, ob.Name); len(values) > 0 {
+				literals := make([]string, len(values))
+				for i, v := range values {
+					b, _ := json.Marshal(v)
+					literals[i] = string(b)
+					if c := description(consts[i].Doc, consts[i].Comment); c != "" {
+						doc += fmt.Sprintf("\n- %s: %s", literals[i], strings.ReplaceAll(c, "\n", " "))
+					}
+				}
+				typ = strings.Join(literals, " | ")
+			}
+		}
+		t.printf("\n")
+		t.jsdoc("", strings.TrimSpace(doc))
+		t.printf("export type %s = %s;\n", ob.Name, typ)
+	}
+}
+
+// extends returns the embedded structs extended by the interface of the struct.
+// The properties shadowed by the outer fields (or by the earlier embedded structs) are omitted (e.g. Omit<Base, "debug">), as encoding/json does.
+func (t *typescript) extends(ob *collect.Object) []string {
+	defined := map[string]bool{}
+	for _, id := range ob.FieldNames {
+		if name, _, ok := t.property(ob.Fields[id]); ok {
+			defined[name] = true
+		}
+	}
+
+	var r []string
+	for _, id := range ob.FieldNames {
+		f := ob.Fields[id]
+		if name, _ := jsonTag(f); !f.Embedded || name != "" {
+			continue
+		}
+		base := lookupStruct(t.p, f.Type)
+		if base == nil {
+			continue
+		}
+		var omit []string
+		for _, field := range jsonFields(t.p, base) {
+			if defined[field.JSONName] {
+				omit = append(omit, fmt.Sprintf("%q", field.JSONName))
+			}
+		}
+		for _, field := range jsonFields(t.p, base) {
+			defined[field.JSONName] = true
+		}
+		if len(omit) > 0 {
+			r = append(r, fmt.Sprintf("Omit<%s, %s>", base.Name, strings.Join(omit, " | ")))
+		} else {
+			r = append(r, base.Name)
+		}
+	}
+	return r
+}
+
+// property returns the property name of the field (and the options of the json tag).
+// If the field is not the property of the object type (e.g. the embedded struct, the unexported field), false is returned.
+func (t *typescript) property(f *collect.Field) (string, string, bool) {
+	name, opts := jsonTag(f)
+	if name == "-" && opts == "" {
+		return "", "", false
+	}
+	if f.Embedded && name == "" && lookupStruct(t.p, f.Type) != nil {
+		return "", "", false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	if name == "" || !token.IsExported(f.Name) {
+		return "", "", false
+	}
+	return name, opts, true
+}
+
+// fields writes the object type of the struct ({ ... }). The embedded structs are not included (extended).
+func (t *typescript) fields(indent string, ob *collect.Object) {
+	t.printf("{\n")
+	for _, id := range ob.FieldNames {
+		f := ob.Fields[id]
+		name, opts, ok := t.property(f)
+		if !ok {
+			continue
+		}
+
+		optional := ""
+		if strings.Contains(","+opts+",", ",omitempty,") || strings.Contains(","+opts+",", ",omitzero,") {
+			optional = "?"
+		}
+		var typ string
+		if f.Anonymous != nil {
+			if f.Anonymous.Token == token.INTERFACE {
+				typ = "unknown"
+			} else {
+				var b strings.Builder
+				inner := &typescript{w: bufio.NewWriter(&b), p: t.p}
+				inner.fields(indent+"\t", f.Anonymous)
+				inner.w.Flush()
+				typ = b.String()
+			}
+		} else if expr, err := parser.ParseExpr(f.Type); err == nil {
+			s, ok := t.Expr(expr, opts)
+			if !ok {
+				continue // e.g. func, chan
+			}
+			typ = s
+		} else {
+			typ = "unknown"
+		}
+
+		t.jsdoc(indent+"\t", description(f.Doc, f.Comment))
+		t.printf("%s\t%s%s: %s;\n", indent, propertyName(name), optional, typ)
+	}
+	t.printf("%s}", indent)
+}
+
+// Expr returns the TypeScript type of the type expression, or false if it cannot be encoded as JSON.
+func (t *typescript) Expr(expr ast.Expr, opts string) (string, bool) {
+	if strings.Contains(","+opts+",", ",string,") {
+		return "string", true
+	}
+	switch x := expr.(type) {
+	case *ast.Ident:
+		switch x.Name {
+		case "bool":
+			return "boolean", true
+		case "string":
+			return "string", true
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune", "float32", "float64":
+			return "number", true
+		case "any":
+			return "unknown", true
+		case "error":
+			return "", false
+		}
+		if ob, ok := t.p.Types[x.Name]; ok {
+			if ob.Underlying != "" {
+				if expr, err := parser.ParseExpr(ob.Underlying); err == nil && !isJSONType(expr) {
+					return "", false
+				}
+			}
+			return x.Name, true
+		}
+		return "unknown", true
+	case *ast.StarExpr:
+		s, ok := t.Expr(x.X, "")
+		if !ok {
+			return "", false
+		}
+		return s + " | null", true
+	case *ast.ParenExpr:
+		return t.Expr(x.X, opts)
+	case *ast.ArrayType:
+		if ident, ok := x.Elt.(*ast.Ident); ok && ident.Name == "byte" && x.Len == nil {
+			return "string", true // base64
+		}
+		s, ok := t.Expr(x.Elt, "")
+		if !ok {
+			return "", false
+		}
+		if strings.Contains(s, " ") {
+			s = "(" + s + ")"
+		}
+		return s + "[]", true
+	case *ast.MapType:
+		s, ok := t.Expr(x.Value, "")
+		if !ok {
+			return "", false
+		}
+		return "Record<string, " + s + ">", true
+	case *ast.SelectorExpr:
+		switch exprString(x) {
+		case "time.Time":
+			return "string", true
+		case "time.Duration":
+			return "number", true
+		}
+		return "unknown", true
+	case *ast.IndexExpr, *ast.IndexListExpr, *ast.InterfaceType, *ast.StructType:
+		return "unknown", true
+	default: // func, chan
+		return "", false
+	}
+}
+
+func (t *typescript) jsdoc(indent string, doc string) {
+	if doc == "" {
+		return
+	}
+	doc = strings.ReplaceAll(doc, "*/", `*\/`)
+	lines := strings.Split(doc, "\n")
+	if len(lines) == 1 {
+		t.printf("%s/** %s */\n", indent, doc)
+		return
+	}
+	t.printf("%s/**\n", indent)
+	for _, line := range lines {
+		t.printf("%s%s\n", indent, strings.TrimRight(" * "+line, " "))
+	}
+	t.printf("%s */\n", indent)
+}
+
+// isJSONType reports whether the type expression can be encoded as JSON (not func or chan).
+func isJSONType(expr ast.Expr) bool {
+	switch x := expr.(type) {
+	case *ast.FuncType, *ast.ChanType:
+		return false
+	case *ast.StarExpr:
+		return isJSONType(x.X)
+	case *ast.ParenExpr:
+		return isJSONType(x.X)
+	default:
+		return true
+	}
+}
+
+func propertyName(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return fmt.Sprintf("%q", name)
+		}
+	}
+	return name
+}
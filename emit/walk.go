@@ -0,0 +1,60 @@
+package emit
+
+import "github.com/podhmo/commentof/collect"
+
+// Entry is a symbol of the package (see Walk).
+type Entry struct {
+	ID      string // e.g. S, S.Nested.ExportedString, *Ob#MarshalJSON, F.x
+	Kind    string // type, interface, func, const, field, method, param or return
+	Doc     string
+	Comment string
+}
+
+// Walk calls visit with the symbols of the package in the source order,
+// including the fields, the methods, the parameters and the return values.
+func Walk(p *collect.Package, visit func(*Entry)) {
+	var visitFunc func(id string, kind string, fn *collect.Func)
+	var visitObject func(kind string, ob *collect.Object)
+	visitFunc = func(id string, kind string, fn *collect.Func) {
+		visit(&Entry{ID: id, Kind: kind, Doc: fn.Doc})
+		for _, name := range fn.ParamNames {
+			f := fn.Params[name]
+			visit(&Entry{ID: id + "." + name, Kind: "param", Doc: f.Doc, Comment: f.Comment})
+		}
+		for _, name := range fn.ReturnNames {
+			f := fn.Returns[name]
+			visit(&Entry{ID: id + "." + name, Kind: "return", Doc: f.Doc, Comment: f.Comment})
+		}
+	}
+	visitObject = func(kind string, ob *collect.Object) {
+		visit(&Entry{ID: ob.Name, Kind: kind, Doc: ob.Doc, Comment: ob.Comment})
+		fieldKind := "field"
+		if kind == "interface" {
+			fieldKind = "method"
+		}
+		for _, name := range ob.FieldNames {
+			f := ob.Fields[name]
+			if f.Anonymous != nil {
+				visitObject(kind, f.Anonymous)
+				continue
+			}
+			visit(&Entry{ID: ob.Name + "." + name, Kind: fieldKind, Doc: f.Doc, Comment: f.Comment})
+		}
+		for _, name := range ob.MethodNames {
+			fn := ob.Methods[name]
+			visitFunc(fn.Recv+"#"+fn.Name, "method", fn)
+		}
+	}
+
+	for _, id := range p.Names {
+		if ob, ok := p.Types[id]; ok {
+			visitObject("type", ob)
+		} else if ob, ok := p.Interfaces[id]; ok {
+			visitObject("interface", ob)
+		} else if fn, ok := p.Functions[id]; ok {
+			visitFunc(id, "func", fn)
+		} else if c, ok := p.Constants[id]; ok {
+			visit(&Entry{ID: id, Kind: "const", Doc: c.Doc, Comment: c.Comment})
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package commentof
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type listedPackage struct {
+	Dir        string // directory containing package sources
+	ImportPath string // import path of package in dir
+	Name       string // package name
+
+	GoFiles      []string // .go source files (excluding CgoFiles, TestGoFiles, XTestGoFiles)
+	CgoFiles     []string // .go source files that import "C"
+	TestGoFiles  []string // _test.go files in package
+	XTestGoFiles []string // _test.go files outside package
+
+	IgnoredGoFiles []string // .go source files ignored due to build constraints (including _test.go files)
+
+	Error *struct {
+		Err string // the error itself
+	}
+}
+
+// goList lists the packages matched by the pattern by invoking the go command.
+// Build tags, GOOS/GOARCH, vendor directories and go.work are handled by the go command.
+func goList(ctx context.Context, cfg *config, pattern string) ([]*listedPackage, error) {
+	args := []string{"list", "-e", "-json=Dir,ImportPath,Name,GoFiles,CgoFiles,TestGoFiles,XTestGoFiles,IgnoredGoFiles,Error"}
+	cmd := exec.CommandContext(ctx, "go")
+	cmd.Env = os.Environ()
+	if p := cfg.Platform; p != nil {
+		if len(p.Tags) > 0 {
+			args = append(args, "-tags", strings.Join(p.Tags, ","))
+		}
+		if p.GOOS != "" {
+			cmd.Env = append(cmd.Env, "GOOS="+p.GOOS)
+		}
+		if p.GOARCH != "" {
+			cmd.Env = append(cmd.Env, "GOARCH="+p.GOARCH)
+		}
+	}
+	if len(cfg.Overlay) > 0 {
+		dir, err := os.MkdirTemp("", "commentof-overlay")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		filename, err := writeOverlay(dir, cfg.Overlay)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-overlay", filename)
+	}
+	cmd.Args = append(append(cmd.Args, args...), "--", pattern)
+
+	out, err := cmd.Output()
+	if ee := (*exec.ExitError)(nil); errors.As(err, &ee) {
+		return nil, fmt.Errorf("go command exited unsuccessfully: %v\n%s", ee.ProcessState.String(), ee.Stderr)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var pkgs []*listedPackage
+	for dec := json.NewDecoder(bytes.NewReader(out)); ; {
+		var pkg listedPackage
+		err := dec.Decode(&pkg)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, &pkg)
+	}
+	return pkgs, nil
+}
+
+// writeOverlay writes the overlay in the format of the go command's -overlay flag, and returns its filename.
+func writeOverlay(dir string, overlay map[string][]byte) (string, error) {
+	replace := make(map[string]string, len(overlay))
+	i := 0
+	for path, src := range overlay {
+		if src == nil {
+			replace[path] = "" // deleted
+			continue
+		}
+		filename := filepath.Join(dir, fmt.Sprintf("%d_%s", i, filepath.Base(path)))
+		i++
+		if err := os.WriteFile(filename, src, 0o644); err != nil {
+			return "", err
+		}
+		replace[path] = filename
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"Replace": replace})
+	if err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir, "overlay.json")
+	if err := os.WriteFile(filename, b, 0o644); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
@@ -0,0 +1,503 @@
+package commentof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+// Dir collects the comments from the packages in the directory (a directory can have the package and its external test package).
+// If the directory is not found, it is looked up in $GOROOT/src (e.g. net/http).
+func Dir(path string, options ...Option) ([]*collect.Package, error) {
+	cfg := defaultConfig()
+	for _, opt := range options {
+		opt(cfg)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		stdpath := filepath.Join(runtime.GOROOT(), "src", path)
+		if stat, err = os.Stat(stdpath); err != nil {
+			return nil, fmt.Errorf("dir %s is not found", path)
+		}
+		path = stdpath
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	return fillImportPath(loadDir(token.NewFileSet(), cfg, path, options))
+}
+
+// Load collects the comments from the packages matched by the patterns.
+// A pattern is a directory, a file, a directory in $GOROOT/src, or a package pattern of the go command (e.g. ./..., std, net/...).
+// The package patterns are resolved by go list (build tags, GOOS/GOARCH, vendor directories and go.work are honored).
+// If the platform is not selected (see WithPlatform), the files for the other platforms are also collected, as variants.
+func Load(patterns []string, options ...Option) ([]*collect.Package, error) {
+	return LoadContext(context.Background(), patterns, options...)
+}
+
+// LoadContext is the context-aware version of Load. The packages are collected concurrently (see WithWorkers),
+// and they are returned in the same order as Load. If an error occurs, the packages before it are returned with the error.
+func LoadContext(ctx context.Context, patterns []string, options ...Option) ([]*collect.Package, error) {
+	cfg := defaultConfig()
+	for _, opt := range options {
+		opt(cfg)
+	}
+	if report := cfg.Report; report != nil { // the callback is not called concurrently
+		var mu sync.Mutex
+		opt := WithReport(func(d *collect.Diagnostic) {
+			mu.Lock()
+			defer mu.Unlock()
+			report(d)
+		})
+		opt(cfg)
+		options = append(options[:len(options):len(options)], opt)
+	}
+
+	fset := token.NewFileSet()
+	var jobs []loadJob
+	for _, pattern := range patterns {
+		jobs = append(jobs, plan(ctx, fset, cfg, pattern, options)...)
+	}
+
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	results := runJobs(ctx, jobs, workers)
+
+	var pkgs []*collect.Package
+	for _, r := range results {
+		pkgs = append(pkgs, r.pkgs...)
+		if r.err != nil {
+			return pkgs, r.err
+		}
+	}
+	return pkgs, nil
+}
+
+// loadJob collects the packages in a unit of work (a directory, a file, or a listed package).
+type loadJob func(ctx context.Context) ([]*collect.Package, error)
+
+type loadResult struct {
+	pkgs []*collect.Package
+	err  error
+}
+
+func runJobs(ctx context.Context, jobs []loadJob, workers int) []loadResult {
+	results := make([]loadResult, len(jobs))
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				if err := ctx.Err(); err != nil {
+					results[i].err = err
+					continue
+				}
+				results[i].pkgs, results[i].err = jobs[i](ctx)
+			}
+		}()
+	}
+	for i := range jobs {
+		ch <- i
+	}
+	close(ch)
+	wg.Wait()
+	return results
+}
+
+func failed(err error) []loadJob {
+	return []loadJob{func(context.Context) ([]*collect.Package, error) { return nil, err }}
+}
+
+// plan resolves the pattern, and returns the jobs for it.
+func plan(ctx context.Context, fset *token.FileSet, cfg *config, pattern string, options []Option) []loadJob {
+	if isPattern(pattern) {
+		return planPackages(ctx, fset, cfg, pattern, options)
+	}
+
+	stat, err := os.Stat(pattern)
+	if err != nil {
+		if _, ok := cfg.Overlay[absPath(pattern)]; ok {
+			return []loadJob{func(context.Context) ([]*collect.Package, error) {
+				return fillImportPath(loadFile(fset, cfg, pattern, options))
+			}}
+		}
+		stdpath := filepath.Join(runtime.GOROOT(), "src", pattern)
+		if stat, err = os.Stat(stdpath); err != nil {
+			return planPackages(ctx, fset, cfg, pattern, options) // import path
+		}
+		pattern = stdpath
+	}
+
+	if stat.IsDir() {
+		return []loadJob{func(context.Context) ([]*collect.Package, error) {
+			return fillImportPath(loadDir(fset, cfg, pattern, options))
+		}}
+	}
+	return []loadJob{func(context.Context) ([]*collect.Package, error) {
+		return fillImportPath(loadFile(fset, cfg, pattern, options))
+	}}
+}
+
+// isPattern reports whether the argument is a package pattern (e.g. ./..., std, net/...).
+func isPattern(arg string) bool {
+	switch arg {
+	case "std", "cmd", "all":
+		return true
+	}
+	return strings.Contains(arg, "...")
+}
+
+func loadDir(fset *token.FileSet, cfg *config, dirname string, options []Option) ([]*collect.Package, error) {
+	filter := func(name string) bool {
+		return !strings.HasSuffix(name, "_test.go")
+	}
+	if cfg.IncludeTestFile {
+		filter = nil
+	}
+	if useCache(cfg) {
+		return loadCachedDir(fset, cfg, dirname, filter, options)
+	}
+	tree, parseErr := parseDir(fset, cfg.Overlay, dirname, filter)
+	if tree == nil {
+		return nil, fmt.Errorf("parse dir: %w", parseErr)
+	}
+
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pkgs := make([]*collect.Package, 0, len(names))
+	for _, name := range names {
+		p, err := Files(fset, tree[name], append(options[:len(options):len(options)], WithParseErrors(parseErr))...)
+		if err != nil {
+			return pkgs, fmt.Errorf("collect: dir=%s, name=%s, %w", dirname, name, err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+func loadFile(fset *token.FileSet, cfg *config, filename string, options []Option) ([]*collect.Package, error) {
+	if useCache(cfg) {
+		p, err := loadCachedFiles(fset, cfg, []string{filename}, options)
+		if err != nil {
+			return nil, fmt.Errorf("collect: file=%s, %w", filename, err)
+		}
+		return []*collect.Package{p}, nil
+	}
+	t, parseErr := parseFile(fset, cfg.Overlay, filename)
+	if t == nil {
+		return nil, fmt.Errorf("parse file: %w", parseErr)
+	}
+
+	p, err := File(fset, t, append(options[:len(options):len(options)], WithParseErrors(parseErr))...)
+	if err != nil {
+		return nil, fmt.Errorf("collect: file=%s, %w", filename, err)
+	}
+	return []*collect.Package{p}, nil
+}
+
+func planPackages(ctx context.Context, fset *token.FileSet, cfg *config, pattern string, options []Option) []loadJob {
+	listed, err := goList(ctx, cfg, pattern)
+	if err != nil {
+		return failed(fmt.Errorf("go list: %w", err))
+	}
+	if len(listed) == 0 {
+		return failed(fmt.Errorf("package %s is not found", pattern))
+	}
+
+	var jobs []loadJob
+	for _, lp := range listed {
+		lp := lp
+		if lp.Error != nil && len(lp.GoFiles) == 0 {
+			return append(jobs, failed(fmt.Errorf("package %s: %s", lp.ImportPath, lp.Error.Err))...)
+		}
+
+		filenames := append(append([]string{}, lp.GoFiles...), lp.CgoFiles...)
+		if cfg.IncludeTestFile {
+			filenames = append(filenames, lp.TestGoFiles...)
+		}
+		xtestFilenames := lp.XTestGoFiles
+		if cfg.Platform == nil { // the files for the other platforms are kept as variants, as loadDir does
+			ignored, xtestIgnored := ignoredFiles(cfg, lp)
+			filenames = append(filenames, ignored...)
+			xtestFilenames = append(append([]string{}, xtestFilenames...), xtestIgnored...)
+		}
+		sort.Strings(filenames)
+		sort.Strings(xtestFilenames)
+		jobs = append(jobs, func(context.Context) ([]*collect.Package, error) {
+			var pkgs []*collect.Package
+			if p, err := loadListedFiles(fset, cfg, lp.Dir, lp.ImportPath, filenames, options); err != nil {
+				return nil, err
+			} else if p != nil {
+				pkgs = append(pkgs, p)
+			}
+
+			if cfg.IncludeTestFile && len(xtestFilenames) > 0 {
+				p, err := loadListedFiles(fset, cfg, lp.Dir, lp.ImportPath+"_test", xtestFilenames, options)
+				if err != nil {
+					return pkgs, err
+				}
+				pkgs = append(pkgs, p)
+			}
+			return pkgs, nil
+		})
+	}
+	return jobs
+}
+
+// ignoredFiles returns the files of the listed package excluded by the build constraints (e.g. *_windows.go on linux),
+// for the package and for the external test package. The files of the other packages (e.g. package main with //go:build ignore) are skipped.
+func ignoredFiles(cfg *config, lp *listedPackage) (filenames []string, xtestFilenames []string) {
+	for _, name := range lp.IgnoredGoFiles {
+		isTest := strings.HasSuffix(name, "_test.go")
+		if isTest && !cfg.IncludeTestFile {
+			continue
+		}
+		switch packageName(cfg.Overlay, filepath.Join(lp.Dir, name)) {
+		case lp.Name:
+			filenames = append(filenames, name)
+		case lp.Name + "_test":
+			if isTest {
+				xtestFilenames = append(xtestFilenames, name)
+			}
+		}
+	}
+	return filenames, xtestFilenames
+}
+
+// packageName returns the package name of the file, or an empty string if it cannot be parsed.
+func packageName(overlay map[string][]byte, filename string) string {
+	var src interface{}
+	if b, ok := overlay[absPath(filename)]; ok {
+		if b == nil {
+			return ""
+		}
+		src = b
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.PackageClauseOnly)
+	if err != nil {
+		return ""
+	}
+	return f.Name.Name
+}
+
+func loadListedFiles(fset *token.FileSet, cfg *config, dir string, importPath string, filenames []string, options []Option) (*collect.Package, error) {
+	if len(filenames) == 0 {
+		return nil, nil
+	}
+
+	if useCache(cfg) {
+		paths := make([]string, len(filenames))
+		for i, name := range filenames {
+			paths[i] = filepath.Join(dir, name)
+		}
+		p, err := loadCachedFiles(fset, cfg, paths, append(options[:len(options):len(options)], WithImportPath(importPath)))
+		if err != nil {
+			return nil, fmt.Errorf("collect: package=%s, %w", importPath, err)
+		}
+		return p, nil
+	}
+
+	files := make([]*ast.File, 0, len(filenames))
+	var errs scanner.ErrorList
+	for _, name := range filenames {
+		filename := filepath.Join(dir, name)
+		f, err := parseFile(fset, cfg.Overlay, filename)
+		if f == nil {
+			return nil, fmt.Errorf("parse file: %w", err)
+		}
+		if err != nil {
+			errs = append(errs, err.(scanner.ErrorList)...)
+		}
+		files = append(files, f)
+	}
+
+	opts := append(options[:len(options):len(options)], WithImportPath(importPath), WithParseErrors(errs.Err()))
+	p, err := Files(fset, files, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("collect: package=%s, %w", importPath, err)
+	}
+	return p, nil
+}
+
+// parseFile is the tolerant version of parser.ParseFile.
+// If the file has syntax errors, the partial AST is returned together with the errors (scanner.ErrorList).
+// If the AST is not available (e.g. the package clause is broken), nil is returned.
+func parseFile(fset *token.FileSet, overlay map[string][]byte, filename string) (*ast.File, error) {
+	var src interface{}
+	if b, ok := overlay[absPath(filename)]; ok {
+		if b == nil {
+			return nil, fmt.Errorf("%s is deleted by the overlay", filename)
+		}
+		src = b
+	}
+
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		var list scanner.ErrorList
+		if !errors.As(err, &list) || !hasPackageClause(f) {
+			return nil, err
+		}
+	}
+	return f, err
+}
+
+// hasPackageClause reports whether the partial AST is available (the package clause is parsed).
+func hasPackageClause(f *ast.File) bool {
+	return f != nil && f.Name != nil && f.Package.IsValid()
+}
+
+// parseDir is the tolerant version of parser.ParseDir (see parseFile), returning the files for each package name.
+func parseDir(fset *token.FileSet, overlay map[string][]byte, dirname string, filter func(name string) bool) (map[string][]*ast.File, error) {
+	filenames, err := listDir(overlay, dirname, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := map[string][]*ast.File{}
+	var errs scanner.ErrorList
+	for _, filename := range filenames {
+		f, err := parseFile(fset, overlay, filename)
+		if err != nil {
+			var list scanner.ErrorList
+			if !errors.As(err, &list) {
+				return nil, err
+			}
+			errs = append(errs, list...)
+		}
+		if f == nil {
+			continue
+		}
+		pkgs[f.Name.Name] = append(pkgs[f.Name.Name], f)
+	}
+	errs.Sort()
+	return pkgs, errs.Err()
+}
+
+// listDir returns the sorted filenames of the go files in the directory.
+// The files only existing in the overlay are also included.
+func listDir(overlay map[string][]byte, dirname string, filter func(name string) bool) ([]string, error) {
+	entries, err := os.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := map[string]bool{}
+	var names []string
+	for _, d := range entries {
+		if d.IsDir() {
+			continue
+		}
+		exists[d.Name()] = true
+		if src, ok := overlay[absPath(filepath.Join(dirname, d.Name()))]; ok && src == nil {
+			continue // deleted
+		}
+		names = append(names, d.Name())
+	}
+	absdir := absPath(dirname)
+	for path, src := range overlay {
+		if src != nil && filepath.Dir(path) == absdir && !exists[filepath.Base(path)] {
+			names = append(names, filepath.Base(path))
+		}
+	}
+	sort.Strings(names)
+
+	filenames := make([]string, 0, len(names))
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".go") || (filter != nil && !filter(name)) {
+			continue
+		}
+		filenames = append(filenames, filepath.Join(dirname, name))
+	}
+	return filenames, nil
+}
+
+// fillImportPath fills the import paths of the packages loaded from the directories (see dirImportPath).
+func fillImportPath(pkgs []*collect.Package, err error) ([]*collect.Package, error) {
+	names := map[string][]string{} // dir -> non-test package names
+	for _, p := range pkgs {
+		if !strings.HasSuffix(p.Name, "_test") {
+			names[p.Dir] = append(names[p.Dir], p.Name)
+		}
+	}
+
+	for _, p := range pkgs {
+		if p.ImportPath != "" || p.Dir == "" {
+			continue
+		}
+		name := strings.TrimSuffix(p.Name, "_test")
+		if len(names[p.Dir]) > 1 && name != filepath.Base(p.Dir) {
+			continue // e.g. package main with //go:build ignore
+		}
+		if path := dirImportPath(p.Dir); path != "" {
+			p.ImportPath = path
+			if strings.HasSuffix(p.Name, "_test") {
+				p.ImportPath += "_test" // external test package
+			}
+		}
+	}
+	return pkgs, err
+}
+
+// dirImportPath guesses the import path of the directory without the go command ($GOROOT/src, or the module path in go.mod).
+// If it is not found, an empty string is returned.
+func dirImportPath(dir string) string {
+	dir = absPath(dir)
+	if rel, err := filepath.Rel(filepath.Join(runtime.GOROOT(), "src"), dir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		if b, err := os.ReadFile(filepath.Join(d, "go.mod")); err == nil {
+			path := modulePath(b)
+			if path == "" {
+				return ""
+			}
+			if rel, _ := filepath.Rel(d, dir); rel != "." {
+				path += "/" + filepath.ToSlash(rel)
+			}
+			return path
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+// modulePath returns the module path in go.mod.
+func modulePath(gomod []byte) string {
+	for _, line := range strings.Split(string(gomod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+func absPath(path string) string {
+	if abspath, err := filepath.Abs(path); err == nil {
+		return abspath
+	}
+	return path
+}
@@ -0,0 +1,258 @@
+package commentof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+func TestRunJobs(t *testing.T) {
+	const n = 8
+	boom := errors.New("boom")
+
+	cases := []struct {
+		name    string
+		workers int
+		fail    int // the index of the failed job (-1: none)
+	}{
+		{name: "serial", workers: 1, fail: -1},
+		{name: "concurrent", workers: 4, fail: -1},
+		{name: "more workers than jobs", workers: n * 2, fail: -1},
+		{name: "concurrent with error", workers: 4, fail: 3},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			jobs := make([]loadJob, n)
+			for i := range jobs {
+				i := i
+				jobs[i] = func(context.Context) ([]*collect.Package, error) {
+					time.Sleep(time.Duration(n-i) * time.Millisecond) // the later jobs finish first
+					if i == c.fail {
+						return nil, boom
+					}
+					return []*collect.Package{{Name: fmt.Sprintf("p%d", i)}}, nil
+				}
+			}
+
+			results := runJobs(context.Background(), jobs, c.workers)
+			if len(results) != n {
+				t.Fatalf("results: want %d, but got %d", n, len(results))
+			}
+			for i, r := range results {
+				if i == c.fail {
+					if r.err != boom {
+						t.Errorf("results[%d]: want the error, but got %+v", i, r.err)
+					}
+					continue
+				}
+				if r.err != nil {
+					t.Errorf("results[%d]: unexpected error: %+v", i, r.err)
+					continue
+				}
+				if want := fmt.Sprintf("p%d", i); len(r.pkgs) != 1 || r.pkgs[0].Name != want {
+					t.Errorf("results[%d]: want %s, but got %v", i, want, r.pkgs)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadContextOrder(t *testing.T) {
+	patterns := []string{
+		"./testdata/fixture",
+		"./testdata/conflict",
+		"./testdata/broken",
+		"./testdata/platform",
+		"./testdata/regression",
+	}
+	load := func(t *testing.T, workers int) []string {
+		t.Helper()
+		pkgs, err := LoadContext(context.Background(), patterns, WithWorkers(workers))
+		if err != nil {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+		var got []string
+		for _, p := range pkgs {
+			got = append(got, fmt.Sprintf("%s %s %v", p.Name, p.Dir, p.Names))
+		}
+		return got
+	}
+
+	want := load(t, 1)
+	if len(want) < len(patterns) {
+		t.Fatalf("packages: want at least %d, but got %d", len(patterns), len(want))
+	}
+	for _, workers := range []int{2, 4, 16} {
+		workers := workers
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			if got := load(t, workers); !reflect.DeepEqual(got, want) {
+				t.Errorf("the order is changed\nwant: %q\n got: %q", want, got)
+			}
+		})
+	}
+}
+
+func TestLoadOverlay(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package p\n\n// A is the A.\ntype A int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		overlay   map[string][]byte
+		wantFiles []string
+		wantNames []string
+	}{
+		{
+			name:      "no overlay",
+			wantFiles: []string{"a.go"},
+			wantNames: []string{"A"},
+		},
+		{
+			name: "replaced",
+			overlay: map[string][]byte{
+				filepath.Join(dir, "a.go"): []byte("package p\n\n// B is the unsaved B.\ntype B int\n"),
+			},
+			wantFiles: []string{"a.go"},
+			wantNames: []string{"B"},
+		},
+		{
+			name: "added",
+			overlay: map[string][]byte{
+				filepath.Join(dir, "b.go"): []byte("package p\n\n// B is the unsaved B.\ntype B int\n"),
+			},
+			wantFiles: []string{"a.go", "b.go"},
+			wantNames: []string{"A", "B"},
+		},
+		{
+			name: "deleted",
+			overlay: map[string][]byte{
+				filepath.Join(dir, "a.go"): nil,
+				filepath.Join(dir, "b.go"): []byte("package p\n\n// B is the unsaved B.\ntype B int\n"),
+			},
+			wantFiles: []string{"b.go"},
+			wantNames: []string{"B"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			pkgs, err := Load([]string{dir}, WithOverlay(c.overlay))
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if len(pkgs) != 1 {
+				t.Fatalf("packages: want 1, but got %d", len(pkgs))
+			}
+			p := pkgs[0]
+			var files []string
+			for _, filename := range p.FileNames {
+				files = append(files, filepath.Base(filename))
+			}
+			if !reflect.DeepEqual(files, c.wantFiles) {
+				t.Errorf("filenames: want %v, but got %v", c.wantFiles, files)
+			}
+			if !reflect.DeepEqual(p.Names, c.wantNames) {
+				t.Errorf("names: want %v, but got %v", c.wantNames, p.Names)
+			}
+		})
+	}
+}
+
+func TestLoadBroken(t *testing.T) {
+	cases := []struct {
+		name    string
+		strict  bool
+		wantErr bool
+	}{
+		{name: "tolerant"},
+		{name: "strict", strict: true, wantErr: true},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			pkgs, err := Load([]string{"./testdata/broken"}, WithStrict(c.strict))
+			if c.wantErr {
+				var d *collect.Diagnostic
+				if !errors.As(err, &d) || d.Code != "syntax-error" {
+					t.Fatalf("want the syntax-error, but got %+v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if len(pkgs) != 1 {
+				t.Fatalf("packages: want 1, but got %d", len(pkgs))
+			}
+			p := pkgs[0]
+
+			if want := []string{"testdata/broken/broken.go"}; !reflect.DeepEqual(p.IncompleteFiles, want) {
+				t.Errorf("incompletefiles: want %v, but got %v", want, p.IncompleteFiles)
+			}
+			for _, d := range p.Diagnostics {
+				if d.Code != "syntax-error" || d.Severity != collect.SeverityError {
+					t.Errorf("unexpected diagnostic: %+v", d)
+				}
+			}
+			if len(p.Diagnostics) == 0 {
+				t.Errorf("diagnostics: want the syntax errors, but got none")
+			}
+
+			// the declarations before the syntax errors are kept, and marked as incomplete
+			if ob, ok := p.Types["Person"]; !ok || !ob.Incomplete {
+				t.Errorf("Person: want the incomplete type, but got %+v", ob)
+			}
+			if fn, ok := p.Functions["Bye"]; !ok || !fn.Incomplete {
+				t.Errorf("Bye: want the incomplete function, but got %+v", fn)
+			}
+			if fn, ok := p.Functions["Hello"]; !ok || fn.Incomplete {
+				t.Errorf("Hello (in ok.go): want the complete function, but got %+v", fn)
+			}
+		})
+	}
+}
+
+func TestLoadConstants(t *testing.T) {
+	cases := []struct {
+		name    string
+		options []Option
+		want    int
+	}{
+		{name: "default", want: 0},
+		{name: "include-constants", options: []Option{WithIncludeConstants(true)}, want: 5},
+		{name: "typecheck", options: []Option{WithTypeCheck(TypeCheckSource)}, want: 5},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			pkgs, err := Load([]string{"./testdata/fixture"}, c.options...)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			p := pkgs[0]
+			if got := len(p.Constants); got != c.want {
+				t.Errorf("constants: want %d, but got %d", c.want, got)
+			}
+			for _, name := range p.Names {
+				if _, ok := p.Constants[name]; !ok && strings.HasPrefix(name, "CONSTNAT_") {
+					t.Errorf("%s is in names, but it is not collected", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package broken
+
+// Person is the person (the definition is half-typed).
+type Person struct {
+	// Name is the name of the person.
+	Name string
+	Age  int,
+}
+
+// Bye says bye.
+func Bye(p Person) string {
+	return "bye " + p.Name +
+}
+
+// Done is the finished one.
+func Done() {}
@@ -0,0 +1,12 @@
+package broken
+
+// Greeting is the message.
+type Greeting struct {
+	// Name is the name of the person.
+	Name string
+}
+
+// Hello says hello.
+func Hello(g Greeting) string {
+	return "hello " + g.Name
+}
@@ -0,0 +1,8 @@
+package conflict
+
+// Hello says hello @D0
+func Hello() string {
+	return "hello"
+}
+
+func init() {}
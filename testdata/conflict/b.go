@@ -0,0 +1,8 @@
+package conflict
+
+// Hello says hello (again) @D1
+func Hello() string {
+	return "hello"
+}
+
+func init() {}
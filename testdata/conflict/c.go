@@ -0,0 +1,17 @@
+package conflict
+
+// Hello is the greeting (conflicts with func Hello) @D2
+type Hello string
+
+// Greeting is the greeting @D3
+type Greeting struct{}
+
+// Say says the greeting @D4
+func (g Greeting) Say() string {
+	return "hello"
+}
+
+// Say says the greeting (pointer receiver, conflicts with Greeting#Say) @D5
+func (g *Greeting) Say() string {
+	return "hello"
+}
@@ -40,3 +40,13 @@ type I3 interface {
 
 	} // embedded anonymous @IF7
 }
+
+// Namer is interface @I5
+type Namer interface {
+	Name() string
+}
+
+// JSONMarshaler is interface @I6
+type JSONMarshaler interface {
+	MarshalJSON() ([]byte, error)
+}
@@ -13,3 +13,58 @@ func (ob Ob) Name() string {
 func (ob *Ob) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf(`{"name": %q}`, ob.Name)), nil
 }
+
+// Ob2 is struct embedding *Ob
+type Ob2 struct {
+	*Ob
+}
+
+// List is generic struct
+type List[T any] struct {
+	items []T
+}
+
+// Push pushes x
+func (l *List[T]) Push(x T) {
+	l.items = append(l.items, x)
+}
+
+// Len returns the length
+func (l List[T]) Len() int {
+	return len(l.items)
+}
+
+// Ob3 is defined type of Ob (the methods of Ob are not inherited)
+type Ob3 Ob
+
+// String returns the name
+func (ob Ob3) String() string {
+	return ob.name
+}
+
+// Ob4 is alias of Ob3 (the methods are the ones of Ob3)
+type Ob4 = Ob3
+
+// Left has Name
+type Left struct{}
+
+// Name returns left
+func (Left) Name() string { return "left" }
+
+// Right has Name
+type Right struct{}
+
+// Name returns right
+func (*Right) Name() string { return "right" }
+
+// Both embeds Left and *Right (Name is ambiguous, so it is not in the method sets)
+type Both struct {
+	Left
+	*Right
+}
+
+// Wrapped embeds Both and Ob (Name of Ob is shallower than the ambiguous one)
+type Wrapped struct {
+	Both
+	Ob
+}
@@ -13,3 +13,6 @@ type MyInt int
 
 // IntAlias is alias
 type IntAlias = int
+
+// I4 is interface @I4
+type I4 I
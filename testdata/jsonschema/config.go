@@ -0,0 +1,60 @@
+package jsonschema
+
+import "time"
+
+// Config is the configuration of the server.
+type Config struct {
+	Name string `json:"name"` // name of the server
+
+	// Port is the listening port.
+	Port int `json:"port,omitempty"`
+
+	// Tags are attached to the metrics.
+	Tags []string `json:"tags,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"` // labels of the server
+
+	Timeout time.Duration `json:"timeout"` // timeout in nanoseconds
+	Started time.Time     `json:"started"` // started time
+
+	// DB is the database setting.
+	DB *DB `json:"db"`
+
+	// Log is the logging setting.
+	Log struct {
+		Level Level `json:"level"` // log level
+		JSON  bool  // output as JSON
+	} `json:"log"`
+
+	Base
+
+	Secret string `json:"-"` // never serialized
+	limit  int
+}
+
+// Base is the common setting.
+type Base struct {
+	Debug bool    `json:"debug,omitempty"` // debug mode
+	Ratio float64 `json:"ratio,string"`    // ratio (encoded as string)
+}
+
+// DB is the database setting.
+type DB struct {
+	DSN string `json:"dsn"` // data source name
+}
+
+// Level is the log level.
+type Level string
+
+const (
+	LevelDebug Level = "debug" // verbose
+	LevelInfo  Level = "info"  // default
+)
+
+// Override is the setting overriding the debug mode of Base (the outer field wins).
+type Override struct {
+	Base
+
+	// Debug is the debug level (shadows Base.Debug).
+	Debug int `json:"debug"`
+}
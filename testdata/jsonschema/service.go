@@ -0,0 +1,31 @@
+package jsonschema
+
+import (
+	"context"
+	"net/netip"
+)
+
+// ConfigService manages the configurations.
+type ConfigService interface {
+	// Get returns the configuration by the name.
+	Get(ctx context.Context, req *GetRequest) (*Config, error)
+
+	// Names returns the names of the configurations.
+	Names() []string
+
+	Lookup(name string, prefix bool) (*Config, error) // Lookup looks up the configuration
+}
+
+// GetRequest is the request of ConfigService.Get.
+type GetRequest struct {
+	Name string `json:"name"` // name of the configuration
+
+	// Addr is the address of the client (not mapped to proto, see TypeMap).
+	Addr netip.Addr `json:"addr"`
+
+	// Version is the version of the configuration (the field number is fixed by the protobuf tag).
+	Version int64 `json:"version" protobuf:"varint,10,opt,name=version,proto3"`
+
+	// Revision is the revision of the configuration.
+	Revision int64 `json:"revision"`
+}
@@ -0,0 +1,67 @@
+package openapi
+
+// Deletes a pet by ID
+//
+// DELETE /pets/{id}
+//
+// deletes a single pet based on the ID supplied
+func DeletePet(input DeletePetInput) struct{}/* pet deleted */ { return struct{}{} }
+
+// DeletePetInput is the input of DeletePet.
+type DeletePetInput struct {
+	ID int64 `path:"id"` // ID of pet to delete
+
+	// Force deletes the pet, even if it has orders.
+	Force bool `query:"force,omitempty"`
+
+	APIKey string `header:"api_key,omitempty"` // API key
+}
+
+// Add a new pet
+//
+// POST /pets
+//
+// adds a new pet to the store
+func AddPet(input *AddPetInput) (*Pet /* the created pet */, error /* invalid input */) {
+	return nil, nil
+}
+
+// AddPetInput is the input of AddPet.
+type AddPetInput struct {
+	TraceID string `header:"X-Trace-ID,omitempty"` // trace ID of the request
+
+	Name string `json:"name"`          // name of the pet
+	Tag  string `json:"tag,omitempty"` // tag of the pet
+}
+
+// Find a pet by ID
+// GET /pets/{id}
+func FindPetByID(
+	id int64, // ID of pet to return
+) (*Pet, error) {
+	return nil, nil
+}
+
+// Pet is a pet in the store.
+type Pet struct {
+	ID   int64  `json:"id"`            // unique ID
+	Name string `json:"name"`          // name of the pet
+	Tag  string `json:"tag,omitempty"` // tag of the pet
+}
+
+// NewPet returns a pet (not a handler, no route).
+func NewPet(name string) *Pet {
+	return &Pet{Name: name}
+}
+
+// Store is the pet store.
+type Store struct{}
+
+// List pets
+//
+// GET /pets
+func (s *Store) ListPets(
+	limit int, // maximum number of pets to return
+) ([]*Pet /* a list of pets */, error) {
+	return nil, nil
+}
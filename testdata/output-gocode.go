@@ -0,0 +1,74 @@
+// Code generated by go-commentof; DO NOT EDIT.
+
+package fixture
+
+// Docs is the documentation of github.com/podhmo/commentof/testdata/fixture, keyed by the ids (e.g. S.Field, *S#Method, F.param).
+var Docs = map[string]string{
+	"CONSTNAT_STRING":         "CONSTANT_STRING is constant string @C0",
+	"CONSTNAT_STRING2":        "CONSTANT_STRING2 is constant string @C1",
+	"CONSTNAT_STRING3":        "CONSTANT_STRING3 is constant string @C2\nCONSTANT_STRING3 is constant string  @C3",
+	"CONSTNAT_STRING4":        "CONSTANT_STRING4 is constant string @C4",
+	"CONSTNAT_STRING5":        "CONSTANT_STRING5 is constant string  @C5",
+	"Base":                    "Base is struct @S10",
+	"Base.ExportedString":     "ExportedString is exported string @F10",
+	"S10":                     "S10 is struct @S10",
+	"S10.ExportedString2":     "ExportedString2 is exported string @F11",
+	"F":                       "F is function @FUN0",
+	"F2":                      "F2 is function @FUN2",
+	"F2.x":                    "x is int @arg1 :IGNORED:",
+	"F2.y":                    "y is int @arg2 :IGNORED:",
+	"F2.args":                 "args is int @arg3 :IGNORED:",
+	"F2.ret#0":                "result of F2 @ret1 :IGNORED:",
+	"F2.ret#1":                "error of F2 @ret2 :IGNORED:",
+	"F3":                      "F3 is function @FUN3",
+	"F4":                      "F4 is function @FUN4",
+	"F4.x":                    "x of F4 @arg4 :IGNORED:\n x of F4 @arg5 :IGNORED:",
+	"F4.y":                    "y of F4 @arg6 :IGNORED:\n y of F4 @arg7 :IGNORED:",
+	"F4.args":                 "arg of F4 @arg8 :IGNORED:",
+	"F4.ret#0":                "result if F4 @ret4 :IGNORED\n ret of F4 @ret5 :IGNORED\n err of F4 @ret6 :IGNORED",
+	"F4.ret#1":                "err of F4 @ret7 :IGNORED",
+	"F5":                      "F5 is function @FUN5",
+	"F7":                      "F7 is function @FUN7",
+	"F8":                      "F8 is function @FUN8",
+	"F8.pretty":               "pretty output or not",
+	"F8.ret#0":                "ret",
+	"F9":                      "F9 is function @FUN9",
+	"F9.pretty":               "pretty output or not",
+	"F9.ret#0":                "ret",
+	"F9.ret#1":                "error",
+	"I":                       "I is interface @I0\nI is interface @I1",
+	"I.Exported":              "Exported is exported method @IF0",
+	"I.Exported2":             "Exported2 is exported method  @IF1",
+	"I.Exported3":             "Exported3 is exported method @IF2\nExported3 is exported method  @IF3",
+	"I2":                      "I2 is interface @I2",
+	"I2.I":                    "embedded I @IF4\nembedded I @IF5",
+	"I2.fmt.Stringer":         "embedded fmt.Stringer @IF6",
+	"I3":                      "I3 is interface @I3",
+	"Namer":                   "Namer is interface @I5",
+	"JSONMarshaler":           "JSONMarshaler is interface @I6",
+	"Ob2":                     "Ob2 is struct embedding *Ob",
+	"List":                    "List is generic struct",
+	"*List#Push":              "Push pushes x",
+	"List#Len":                "Len returns the length",
+	"Ob3":                     "Ob3 is defined type of Ob (the methods of Ob are not inherited)",
+	"Ob3#String":              "String returns the name",
+	"Ob4":                     "Ob4 is alias of Ob3 (the methods are the ones of Ob3)",
+	"Left":                    "Left has Name",
+	"Left#Name":               "Name returns left",
+	"Right":                   "Right has Name",
+	"*Right#Name":             "Name returns right",
+	"Both":                    "Both embeds Left and *Right (Name is ambiguous, so it is not in the method sets)",
+	"Wrapped":                 "Wrapped embeds Both and Ob (Name of Ob is shallower than the ambiguous one)",
+	"S":                       "S is struct @S0\nS is struct @S1",
+	"S.ExportedString":        "ExportedString is exported string @F0",
+	"S.ExportedString2":       "ExportedString2 is exported string @F1",
+	"S.ExportedString3":       "ExportedString3 is exported string @F2\nExportedString3 is exported string @F3",
+	"S.Nested":                "Nested is struct @SS0\nNested is struct @SS1",
+	"S.Nested.ExportedString": "ExportedString is exported string @FF0\nExportedString is exported string @FF1",
+	"S2":                      "S2 is struct @S2",
+	"S3":                      "S3 is struct @S3",
+	"EmitFunc":                "EmitFunc is function",
+	"MyInt":                   "MyInt is new type",
+	"IntAlias":                "IntAlias is alias",
+	"I4":                      "I4 is interface @I4",
+}
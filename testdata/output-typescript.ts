@@ -0,0 +1,65 @@
+// package jsonschema (github.com/podhmo/commentof/testdata/jsonschema)
+
+/** Config is the configuration of the server. */
+export interface Config extends Base {
+	/** name of the server */
+	name: string;
+	/** Port is the listening port. */
+	port?: number;
+	/** Tags are attached to the metrics. */
+	tags?: string[];
+	/** labels of the server */
+	labels?: Record<string, string>;
+	/** timeout in nanoseconds */
+	timeout: number;
+	/** started time */
+	started: string;
+	/** DB is the database setting. */
+	db: DB | null;
+	/** Log is the logging setting. */
+	log: {
+		/** log level */
+		level: Level;
+		/** output as JSON */
+		JSON: boolean;
+	};
+}
+
+/** Base is the common setting. */
+export interface Base {
+	/** debug mode */
+	debug?: boolean;
+	/** ratio (encoded as string) */
+	ratio: string;
+}
+
+/** DB is the database setting. */
+export interface DB {
+	/** data source name */
+	dsn: string;
+}
+
+/**
+ * Level is the log level.
+ * - "debug": verbose
+ * - "info": default
+ */
+export type Level = "debug" | "info";
+
+/** Override is the setting overriding the debug mode of Base (the outer field wins). */
+export interface Override extends Omit<Base, "debug"> {
+	/** Debug is the debug level (shadows Base.Debug). */
+	debug: number;
+}
+
+/** GetRequest is the request of ConfigService.Get. */
+export interface GetRequest {
+	/** name of the configuration */
+	name: string;
+	/** Addr is the address of the client (not mapped to proto, see TypeMap). */
+	addr: unknown;
+	/** Version is the version of the configuration (the field number is fixed by the protobuf tag). */
+	version: number;
+	/** Revision is the revision of the configuration. */
+	revision: number;
+}
@@ -0,0 +1,8 @@
+//go:build cgo && (linux || darwin)
+
+package platform
+
+// UseCgo reports whether cgo is used @P2
+func UseCgo() bool {
+	return true
+}
@@ -0,0 +1,6 @@
+package platform
+
+// Open opens the file (windows) @P1
+func Open(name string) error {
+	return nil
+}
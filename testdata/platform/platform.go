@@ -0,0 +1,4 @@
+package platform
+
+// Mode is the mode of the file @P3
+type Mode int
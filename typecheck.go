@@ -0,0 +1,162 @@
+package commentof
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/podhmo/commentof/collect"
+)
+
+type TypeCheckMode int
+
+const (
+	NoTypeCheck     TypeCheckMode = iota
+	TypeCheckSource               // go/types with the source importer
+	TypeCheckExport               // go/types with the export data built by go list -export
+)
+
+func ParseTypeCheckMode(s string) (TypeCheckMode, error) {
+	switch s {
+	case "", "none":
+		return NoTypeCheck, nil
+	case "source":
+		return TypeCheckSource, nil
+	case "export":
+		return TypeCheckExport, nil
+	default:
+		return NoTypeCheck, fmt.Errorf("unexpected type check mode: %q (none, source, export)", s)
+	}
+}
+
+// typeCheckPlatform returns the platform of the type-checked files, the selected one or the host platform.
+func typeCheckPlatform(cfg *config) *collect.Platform {
+	if cfg.Platform != nil {
+		return cfg.Platform
+	}
+	ctxt := build.Default
+	tags := append([]string{}, ctxt.BuildTags...)
+	if ctxt.CgoEnabled {
+		tags = append(tags, "cgo")
+	}
+	return &collect.Platform{GOOS: ctxt.GOOS, GOARCH: ctxt.GOARCH, Tags: tags}
+}
+
+func typeCheck(fset *token.FileSet, c *collect.Collector, cfg *config, files []*ast.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+	dir, err := filepath.Abs(filepath.Dir(fset.File(files[0].Pos()).Name()))
+	if err != nil {
+		return fmt.Errorf("type check: %w", err)
+	}
+
+	var imp types.Importer
+	switch cfg.TypeCheck {
+	case TypeCheckSource:
+		imp = importer.ForCompiler(fset, "source", nil)
+	case TypeCheckExport:
+		imp, err = exportImporter(fset, dir)
+		if err != nil {
+			return fmt.Errorf("type check: %w", err)
+		}
+	default:
+		return fmt.Errorf("type check: unexpected mode: %d", cfg.TypeCheck)
+	}
+
+	path := cfg.ImportPath
+	if path == "" {
+		path = guessImportPath(dir, files[0].Name.Name)
+		cfg.Package.ImportPath = path
+	}
+
+	tc := &collect.TypeChecker{Fset: fset, Importer: imp, Collector: c, Dir: dir}
+	pkg, err := tc.Check(cfg.Package, path, files)
+	if err != nil {
+		return fmt.Errorf("type check: %w", err)
+	}
+	cfg.TypesPackage = pkg
+
+	// type errors are reported as diagnostics
+	for _, err := range tc.Errors {
+		d := &collect.Diagnostic{Severity: collect.SeverityWarning, Code: "type-error", Message: err.Error()}
+		if terr, ok := err.(types.Error); ok {
+			d.Pos = terr.Pos
+			d.Position = fset.Position(terr.Pos).String()
+			d.Message = terr.Msg
+			if terr.Soft {
+				d.Severity = collect.SeverityInfo
+			}
+		}
+		cfg.Package.Diagnostics = append(cfg.Package.Diagnostics, d)
+		if cfg.Report != nil {
+			cfg.Report(d)
+		}
+		if cfg.Strict && d.Severity != collect.SeverityInfo {
+			return d
+		}
+	}
+	return nil
+}
+
+// guessImportPath returns the import path of the package in the directory by invoking the go command.
+// If it is failed, the package name is used.
+func guessImportPath(dir string, name string) string {
+	cmd := exec.Command("go", "list", "-e", "-find", "-f", "{{.ImportPath}}", ".")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	path := strings.TrimSpace(string(out))
+	if err != nil || path == "" || strings.HasPrefix(path, "_") { // _/path/to/dir (outside of modules)
+		return name
+	}
+	if strings.HasSuffix(name, "_test") && !strings.HasSuffix(path, "_test") {
+		path += "_test" // external test package
+	}
+	return path
+}
+
+// exportImporter returns the importer reading the export data built by go list -export.
+func exportImporter(fset *token.FileSet, dir string) (types.Importer, error) {
+	cmd := exec.Command("go", "list", "-e", "-export", "-deps", "-json=ImportPath,Export", ".")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if ee := (*exec.ExitError)(nil); errors.As(err, &ee) {
+		return nil, fmt.Errorf("go command exited unsuccessfully: %v\n%s", ee.ProcessState.String(), ee.Stderr)
+	} else if err != nil {
+		return nil, err
+	}
+
+	exports := map[string]string{}
+	for dec := json.NewDecoder(bytes.NewReader(out)); ; {
+		var pkg struct {
+			ImportPath string
+			Export     string
+		}
+		err := dec.Decode(&pkg)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		exports[pkg.ImportPath] = pkg.Export
+	}
+
+	return importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		filename := exports[path]
+		if filename == "" {
+			return nil, fmt.Errorf("export data is not found: %s", path)
+		}
+		return os.Open(filename)
+	}), nil
+}
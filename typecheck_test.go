@@ -0,0 +1,43 @@
+package commentof
+
+import (
+	"testing"
+)
+
+func TestTypeCheckPlatformVariants(t *testing.T) {
+	cases := []struct {
+		name         string
+		options      []Option
+		wantVariants int
+	}{
+		{name: "host platform", wantVariants: 1},
+		{name: "windows", options: []Option{WithPlatform("windows", "amd64")}, wantVariants: 0},
+		{name: "linux", options: []Option{WithPlatform("linux", "amd64")}, wantVariants: 0},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			options := append([]Option{WithTypeCheck(TypeCheckSource), WithStrict(true)}, c.options...)
+			pkgs, err := Load([]string{"./testdata/platform"}, options...)
+			if err != nil {
+				t.Fatalf("unexpected error (the variants are type-checked?): %+v", err)
+			}
+			p := pkgs[0]
+			for _, d := range p.Diagnostics {
+				t.Errorf("unexpected diagnostic: %+v", d)
+			}
+
+			fn, ok := p.Functions["Open"]
+			if !ok {
+				t.Fatalf("Open is not found")
+			}
+			if got := len(fn.Variants); got != c.wantVariants {
+				t.Errorf("variants: want %d, but got %d", c.wantVariants, got)
+			}
+			if got := fn.Params["name"].ResolvedType; got != "string" {
+				t.Errorf("the resolved type of the param: want string, but got %q", got)
+			}
+		})
+	}
+}